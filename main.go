@@ -7,12 +7,8 @@ import (
 	"os"
 
 	"github.com/joho/godotenv"
-	"github.com/proGabby/simple_auth_todo_api/pkg/controllers"
 	"github.com/proGabby/simple_auth_todo_api/pkg/data/database"
-	"github.com/proGabby/simple_auth_todo_api/pkg/middlewares"
-	"github.com/proGabby/simple_auth_todo_api/pkg/models"
-
-	"github.com/gorilla/mux"
+	"github.com/proGabby/simple_auth_todo_api/pkg/server"
 )
 
 func main() {
@@ -31,8 +27,6 @@ func main() {
 		log.Fatal("PORT environment variable not set")
 	}
 
-	r := mux.NewRouter()
-
 	// Initialize the DB
 	db, err := database.InitDB()
 
@@ -40,28 +34,18 @@ func main() {
 		log.Fatal(err)
 	}
 
-	todoStore := models.NewTodoStore(db)
-	userStore := models.NewUserStore(db)
-
-	// Middleware for authentication
-	authMiddleware := middlewares.NewAuthMiddleware(*userStore)
-
-	// Middleware for permission
-	permissionMiddleware := middlewares.NewPermissionMiddleware(authMiddleware, *todoStore)
+	// Apply pending schema migrations
+	if err := database.Migrate(db); err != nil {
+		log.Fatal(err)
+	}
 
-	// Initialize controllers
-	todoController := controllers.NewTodoController(*todoStore)
-	userController := controllers.NewUserController(*userStore)
+	// Assemble the API and start its background jobs
+	srv, err := server.New(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv.StartMaintenance()
 
-	// Routes
-	r.HandleFunc("/login", userController.LoginUser).Methods("POST")
-	r.HandleFunc("/register", userController.RegisterUser).Methods("POST")
-	r.HandleFunc("/user/details", authMiddleware.Authenticate(userController.GetUserByToken)).Methods("GET")
-	r.HandleFunc("/todos", authMiddleware.Authenticate(todoController.GetTodosByUser)).Methods("GET")
-	r.HandleFunc("/todos", authMiddleware.Authenticate(todoController.CreateTodo)).Methods("POST")
-	r.HandleFunc("/todos/{id}", authMiddleware.Authenticate(todoController.GetSingleTodo)).Methods("GET")
-	r.HandleFunc("/todos/update", authMiddleware.Authenticate(permissionMiddleware.Authorize([]string{"user"}, todoController.UpdateTodo))).Methods("PUT")
-	r.HandleFunc("/todos", authMiddleware.Authenticate(permissionMiddleware.Authorize([]string{"admin"}, todoController.DeleteTodo))).Methods("DELETE")
 	fmt.Println("before listening on port 8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	log.Fatal(http.ListenAndServe(":8080", srv.Handler))
 }
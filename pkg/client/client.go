@@ -0,0 +1,291 @@
+// Package client is a Go client for the todo API. It signs in, keeps the
+// token and signs in again when it expires, retries requests that are safe to
+// repeat, and returns the API's errors as *APIError values.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refreshBefore is how long before its expiry a token is replaced.
+const refreshBefore = time.Minute
+
+// TokenStore keeps the token of a Client, for example to share it between
+// processes. It must be safe for concurrent use.
+type TokenStore interface {
+	Token() string
+	SetToken(token string)
+}
+
+// MemoryTokenStore keeps a token in memory.
+type MemoryTokenStore struct {
+	mu    sync.Mutex
+	token string
+}
+
+// Token returns the stored token.
+func (s *MemoryTokenStore) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+// SetToken replaces the stored token.
+func (s *MemoryTokenStore) SetToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+}
+
+// Client calls the todo API.
+type Client struct {
+	// BaseURL is the address of the API, such as "https://todo.example.com".
+	BaseURL string
+	// HTTPClient sends the requests.
+	HTTPClient *http.Client
+	// Tokens keeps the token sent with requests.
+	Tokens TokenStore
+	// MaxRetries is how many times requests that are safe to repeat are
+	// retried after network errors, rate limiting and unavailable servers.
+	MaxRetries int
+	// MaxRetryWait caps the wait before a retry asked for by Retry-After, so
+	// that a server cannot hold the client for longer.
+	MaxRetryWait time.Duration
+
+	// The credentials of the last Login, used to sign in again
+	mu       sync.Mutex
+	username string
+	password string
+}
+
+// New creates a new Client for the API at baseURL.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		HTTPClient:   http.DefaultClient,
+		Tokens:       &MemoryTokenStore{},
+		MaxRetries:   3,
+		MaxRetryWait: backoff(6),
+	}
+}
+
+// request describes an API call.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        interface{}
+	contentType string
+	header      http.Header
+	// anonymous requests are sent without a token
+	anonymous bool
+	// idempotent requests may be sent again after a failure
+	idempotent bool
+}
+
+// withIdempotencyKey marks a POST request as safe to retry by sending it with
+// a new Idempotency-Key, so that the API applies it only once.
+func (req *request) withIdempotencyKey() *request {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return req
+	}
+
+	if req.header == nil {
+		req.header = http.Header{}
+	}
+	req.header.Set("Idempotency-Key", hex.EncodeToString(key))
+	req.idempotent = true
+	return req
+}
+
+// do sends req and decodes the JSON response into out, if not nil.
+func (c *Client) do(ctx context.Context, req *request, out interface{}) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return err
+		}
+	}
+	idempotent := req.idempotent || req.method == http.MethodGet || req.method == http.MethodPut || req.method == http.MethodDelete
+
+	reauthenticated := false
+	for attempt := 0; ; attempt++ {
+		if !req.anonymous {
+			if err := c.ensureToken(ctx); err != nil {
+				return err
+			}
+		}
+
+		resp, err := c.send(ctx, req, body)
+		if err != nil {
+			if !idempotent || attempt >= c.MaxRetries || ctx.Err() != nil {
+				return err
+			}
+			if err := sleep(ctx, backoff(attempt)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// An expired or revoked token is replaced once by signing in again
+		if resp.StatusCode == http.StatusUnauthorized && !req.anonymous && !reauthenticated && c.canLogin() {
+			resp.Body.Close()
+			reauthenticated = true
+			if err := c.relogin(ctx); err != nil {
+				return err
+			}
+			attempt--
+			continue
+		}
+
+		if retryable(resp.StatusCode) && idempotent && attempt < c.MaxRetries {
+			wait := backoff(attempt)
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				wait = time.Duration(seconds) * time.Second
+			}
+			if wait > c.MaxRetryWait {
+				wait = c.MaxRetryWait
+			}
+			resp.Body.Close()
+			if err := sleep(ctx, wait); err != nil {
+				return err
+			}
+			continue
+		}
+
+		defer resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			return decodeError(resp)
+		}
+		if out == nil {
+			return nil
+		}
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+}
+
+func (c *Client) send(ctx context.Context, req *request, body []byte) (*http.Response, error) {
+	target := c.BaseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range req.header {
+		httpReq.Header[name] = values
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		contentType := req.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if !req.anonymous {
+		httpReq.Header.Set("Authorization", "Bearer "+c.Tokens.Token())
+	}
+
+	return c.HTTPClient.Do(httpReq)
+}
+
+// ensureToken signs in again if the token is about to expire and the
+// credentials are known.
+func (c *Client) ensureToken(ctx context.Context) error {
+	if token := c.Tokens.Token(); token != "" {
+		expiry, ok := tokenExpiry(token)
+		if !ok || time.Until(expiry) > refreshBefore || !c.canLogin() {
+			return nil
+		}
+	} else if !c.canLogin() {
+		return errNotSignedIn
+	}
+
+	return c.relogin(ctx)
+}
+
+func (c *Client) canLogin() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.username != ""
+}
+
+func (c *Client) relogin(ctx context.Context) error {
+	c.mu.Lock()
+	username, password := c.username, c.password
+	c.mu.Unlock()
+
+	_, err := c.Login(ctx, username, password)
+	return err
+}
+
+// tokenExpiry reads the expiry of a JWT without verifying it.
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ExpiresAt == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.ExpiresAt, 0), true
+}
+
+// retryable reports whether a request that failed with status may succeed if sent again.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns how long to wait before the retry after attempt.
+func backoff(attempt int) time.Duration {
+	if attempt >= 6 {
+		return 5 * time.Second
+	}
+	return 100 * time.Millisecond << attempt
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
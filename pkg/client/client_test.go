@@ -0,0 +1,290 @@
+package client_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/proGabby/simple_auth_todo_api/pkg/client"
+	"github.com/proGabby/simple_auth_todo_api/pkg/data/database"
+	"github.com/proGabby/simple_auth_todo_api/pkg/server"
+)
+
+// failure is a response that faultyHandler sends instead of the API's.
+type failure struct {
+	status     int
+	retryAfter int
+	// afterServing lets the API handle the request before its response is
+	// replaced, like a response lost on the way back to the client.
+	afterServing bool
+}
+
+// faultyHandler passes requests to the API, answering the first ones for a
+// route with the failures queued for it, and counts the requests of each route.
+type faultyHandler struct {
+	api http.Handler
+
+	mu       sync.Mutex
+	failures map[string][]failure
+	requests map[string][]*http.Request
+}
+
+// fail queues failures for the requests with method to path.
+func (h *faultyHandler) fail(method, path string, failures ...failure) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures[method+" "+path] = append(h.failures[method+" "+path], failures...)
+}
+
+// received returns the requests with method to path so far.
+func (h *faultyHandler) received(method, path string) []*http.Request {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.requests[method+" "+path]
+}
+
+func (h *faultyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route := r.Method + " " + r.URL.Path
+	h.mu.Lock()
+	h.requests[route] = append(h.requests[route], r.Clone(context.Background()))
+	queued := h.failures[route]
+	var f *failure
+	if len(queued) > 0 {
+		f, h.failures[route] = &queued[0], queued[1:]
+	}
+	h.mu.Unlock()
+
+	if f == nil {
+		h.api.ServeHTTP(w, r)
+		return
+	}
+	if f.afterServing {
+		h.api.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if f.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(f.retryAfter))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(f.status)
+	fmt.Fprintf(w, `{"error":%q,"message":"injected failure"}`, http.StatusText(f.status))
+}
+
+// newTestClient runs the API on the database in DATABASE_URL behind a
+// faultyHandler and returns a client for it with a new user's credentials,
+// skipping the test when DATABASE_URL is not set.
+func newTestClient(t *testing.T) (*client.Client, *faultyHandler, string) {
+	t.Helper()
+	connStr := os.Getenv("DATABASE_URL")
+	if connStr == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+	if os.Getenv("JWT_SECRET_KEY") == "" {
+		t.Setenv("JWT_SECRET_KEY", "test-secret")
+	}
+	t.Setenv("RATE_LIMIT_STORE", "memory")
+
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	srv, err := server.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := &faultyHandler{api: srv.Handler, failures: map[string][]failure{}, requests: map[string][]*http.Request{}}
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	username := fmt.Sprintf("client-%d", time.Now().UnixNano())
+	return client.New(ts.URL), handler, username
+}
+
+func TestLoginKeepsToken(t *testing.T) {
+	c, _, username := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.CurrentUser(ctx); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("CurrentUser before Login: got %v, want ErrUnauthorized", err)
+	}
+
+	registered, err := c.Register(ctx, username, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Tokens.Token() != "" {
+		t.Error("Register signed in")
+	}
+
+	user, err := c.Login(ctx, username, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != registered.ID {
+		t.Errorf("Login returned user %d, want %d", user.ID, registered.ID)
+	}
+	if c.Tokens.Token() == "" {
+		t.Fatal("Login did not keep the token")
+	}
+
+	current, err := c.CurrentUser(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Username != username {
+		t.Errorf("CurrentUser returned %q, want %q", current.Username, username)
+	}
+}
+
+func TestSignsInAgainOnUnauthorized(t *testing.T) {
+	c, handler, username := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.Register(ctx, username, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Login(ctx, username, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A token the API does not accept, as after a change of signing key
+	c.Tokens.SetToken("revoked")
+	if _, err := c.CurrentUser(ctx); err != nil {
+		t.Fatalf("CurrentUser with a revoked token: %v", err)
+	}
+	if c.Tokens.Token() == "revoked" {
+		t.Error("the revoked token was not replaced")
+	}
+	if logins := len(handler.received("POST", "/login")); logins != 2 {
+		t.Errorf("signed in %d times, want 2", logins)
+	}
+}
+
+func TestRetriesAfterRetryAfter(t *testing.T) {
+	c, handler, username := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.Register(ctx, username, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Login(ctx, username, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	handler.fail("GET", "/user/details",
+		failure{status: http.StatusTooManyRequests, retryAfter: 1},
+		failure{status: http.StatusServiceUnavailable, retryAfter: 1},
+	)
+	start := time.Now()
+	if _, err := c.CurrentUser(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Errorf("retried after %v, want the 2s asked by Retry-After", elapsed)
+	}
+	if attempts := len(handler.received("GET", "/user/details")); attempts != 3 {
+		t.Errorf("sent %d requests, want 3", attempts)
+	}
+
+	// Requests are given up after MaxRetries
+	c.MaxRetries = 1
+	handler.fail("GET", "/user/details",
+		failure{status: http.StatusTooManyRequests, retryAfter: 1},
+		failure{status: http.StatusTooManyRequests, retryAfter: 1},
+	)
+	if _, err := c.CurrentUser(ctx); !errors.Is(err, client.ErrRateLimited) {
+		t.Errorf("CurrentUser past MaxRetries: got %v, want ErrRateLimited", err)
+	}
+}
+
+func TestCreateTodoReplaysRetries(t *testing.T) {
+	c, handler, username := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.Register(ctx, username, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Login(ctx, username, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The todo is created but the response is lost
+	handler.fail("POST", "/todos", failure{status: http.StatusServiceUnavailable, afterServing: true})
+	todo, err := c.CreateTodo(ctx, client.NewTodo{Title: "Buy milk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := handler.received("POST", "/todos")
+	if len(requests) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(requests))
+	}
+	key := requests[0].Header.Get("Idempotency-Key")
+	if key == "" || requests[1].Header.Get("Idempotency-Key") != key {
+		t.Errorf("retried with Idempotency-Key %q after %q", requests[1].Header.Get("Idempotency-Key"), key)
+	}
+
+	todos, err := c.ListTodos(ctx, client.TodoListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 1 || todos[0].ID != todo.ID {
+		t.Errorf("ListTodos returned %d todos, want only todo %d", len(todos), todo.ID)
+	}
+}
+
+func TestDecodesErrors(t *testing.T) {
+	c, _, username := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := c.Register(ctx, username, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	// /login answers errors in plain text
+	_, err := c.Login(ctx, username, "wrong")
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("Login with a wrong password: got %v, want an APIError matching ErrUnauthorized", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "Invalid username or password" {
+		t.Errorf("Login with a wrong password: got %+v", apiErr)
+	}
+
+	if _, err := c.Login(ctx, username, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Other routes answer them as JSON
+	_, err = c.GetTodo(ctx, 0)
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("GetTodo of a missing todo: got %v, want an APIError matching ErrNotFound", err)
+	}
+	if apiErr.Code != "Not Found" || apiErr.Message != "Todo not found" {
+		t.Errorf("GetTodo of a missing todo: got %+v", apiErr)
+	}
+
+	todo, err := c.CreateTodo(ctx, client.NewTodo{Title: "Buy milk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	title := "Buy oat milk"
+	if _, err := c.UpdateTodo(ctx, todo.ID, client.TodoUpdate{Title: &title}, todo.ETag); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdateTodo(ctx, todo.ID, client.TodoUpdate{Title: &title}, todo.ETag); !errors.Is(err, client.ErrPreconditionFailed) {
+		t.Errorf("UpdateTodo with a stale ETag: got %v, want ErrPreconditionFailed", err)
+	}
+}
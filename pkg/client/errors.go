@@ -0,0 +1,78 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Errors matched by the APIError of responses with the corresponding status,
+// for use with errors.Is.
+var (
+	ErrBadRequest           = errors.New("bad request")
+	ErrUnauthorized         = errors.New("unauthorized")
+	ErrForbidden            = errors.New("forbidden")
+	ErrNotFound             = errors.New("not found")
+	ErrConflict             = errors.New("conflict")
+	ErrPreconditionFailed   = errors.New("precondition failed")
+	ErrPreconditionRequired = errors.New("precondition required")
+	ErrUnprocessable        = errors.New("unprocessable entity")
+	ErrRateLimited          = errors.New("rate limited")
+)
+
+var (
+	// errNoCredentials is returned by Login and Register when called without credentials.
+	errNoCredentials = errors.New("todo API: username and password are required")
+	// errNotSignedIn is returned for calls that need a token before Login.
+	errNotSignedIn = fmt.Errorf("todo API: not signed in: %w", ErrUnauthorized)
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:           ErrBadRequest,
+	http.StatusUnauthorized:         ErrUnauthorized,
+	http.StatusForbidden:            ErrForbidden,
+	http.StatusNotFound:             ErrNotFound,
+	http.StatusConflict:             ErrConflict,
+	http.StatusPreconditionFailed:   ErrPreconditionFailed,
+	http.StatusPreconditionRequired: ErrPreconditionRequired,
+	http.StatusUnprocessableEntity:  ErrUnprocessable,
+	http.StatusTooManyRequests:      ErrRateLimited,
+}
+
+// APIError is an error response of the API.
+type APIError struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Code is the short name of the error, such as "Not Found".
+	Code string `json:"error"`
+	// Message explains the error.
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("todo API: %d %s", e.StatusCode, e.Code)
+	}
+	return fmt.Sprintf("todo API: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is reports whether target is the error variable for the status of e, such as ErrNotFound for a 404.
+func (e *APIError) Is(target error) bool {
+	return statusErrors[e.StatusCode] == target
+}
+
+// decodeError reads the error of a response. Most routes answer errors as
+// JSON objects, while /login and /register answer them in plain text.
+func decodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Code == "" {
+		apiErr.Code = http.StatusText(resp.StatusCode)
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
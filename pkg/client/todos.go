@@ -0,0 +1,202 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxPageSize is the largest page of todos the API returns.
+const maxPageSize = 100
+
+func todoPath(id int) string {
+	return "/todos/" + strconv.Itoa(id)
+}
+
+// ifMatch returns the header for an If-Match ETag, if any.
+func ifMatch(etag string) http.Header {
+	if etag == "" {
+		return nil
+	}
+	return http.Header{"If-Match": {etag}}
+}
+
+// ListTodos returns the user's personal todos and the todos shared with them
+// matching opts.
+func (c *Client) ListTodos(ctx context.Context, opts TodoListOptions) ([]Todo, error) {
+	query := url.Values{}
+	if opts.Status != "" {
+		query.Set("status", opts.Status)
+	}
+	if opts.Shared != "" {
+		query.Set("shared", opts.Shared)
+	}
+	if len(opts.Tags) > 0 {
+		query.Set("tags", strings.Join(opts.Tags, ","))
+	}
+	if opts.TagMode != "" {
+		query.Set("tag_mode", opts.TagMode)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		query.Set("offset", strconv.Itoa(opts.Offset))
+	}
+
+	var todos []Todo
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/todos", query: query}, &todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// TodoPager lists the user's todos a page at a time:
+//
+//	pager := c.TodoPages(opts)
+//	for pager.Next(ctx) {
+//		for _, todo := range pager.Todos() {
+//			...
+//		}
+//	}
+//	if err := pager.Err(); err != nil {
+//		...
+//	}
+type TodoPager struct {
+	client *Client
+	opts   TodoListOptions
+	todos  []Todo
+	done   bool
+	err    error
+}
+
+// TodoPages returns a pager over the todos matching opts, starting at
+// opts.Offset, in pages of opts.Limit todos (100 if zero).
+func (c *Client) TodoPages(opts TodoListOptions) *TodoPager {
+	if opts.Limit <= 0 || opts.Limit > maxPageSize {
+		opts.Limit = maxPageSize
+	}
+	return &TodoPager{client: c, opts: opts}
+}
+
+// Next fetches the next page, returning false when there are no more todos or
+// an error occurred.
+func (p *TodoPager) Next(ctx context.Context) bool {
+	if p.done {
+		return false
+	}
+
+	p.todos, p.err = p.client.ListTodos(ctx, p.opts)
+	if p.err != nil || len(p.todos) == 0 {
+		p.done = true
+		return false
+	}
+
+	p.opts.Offset += len(p.todos)
+	p.done = len(p.todos) < p.opts.Limit
+	return true
+}
+
+// Todos returns the current page.
+func (p *TodoPager) Todos() []Todo {
+	return p.todos
+}
+
+// Err returns the error that stopped the pager, if any.
+func (p *TodoPager) Err() error {
+	return p.err
+}
+
+// GetTodo returns a todo.
+func (c *Client) GetTodo(ctx context.Context, id int) (*Todo, error) {
+	var todo Todo
+	if err := c.do(ctx, &request{method: http.MethodGet, path: todoPath(id)}, &todo); err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// CreateTodo creates a todo. It is sent with an Idempotency-Key, so it is
+// retried without risk of creating the todo twice.
+func (c *Client) CreateTodo(ctx context.Context, newTodo NewTodo) (*Todo, error) {
+	var todo Todo
+	req := &request{method: http.MethodPost, path: "/todos", body: newTodo}
+	if err := c.do(ctx, req.withIdempotencyKey(), &todo); err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// UpdateTodo changes the fields of a todo set in update. If etag is not
+// empty, the todo is only changed if it still matches it; otherwise the error
+// matches ErrPreconditionFailed.
+func (c *Client) UpdateTodo(ctx context.Context, id int, update TodoUpdate, etag string) (*Todo, error) {
+	var todo Todo
+	err := c.do(ctx, &request{
+		method:      http.MethodPatch,
+		path:        todoPath(id),
+		body:        update.mergePatch(),
+		contentType: "application/merge-patch+json",
+		header:      ifMatch(etag),
+	}, &todo)
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// ReplaceTodo replaces every editable field of a todo. If etag is not empty,
+// the todo is only changed if it still matches it.
+func (c *Client) ReplaceTodo(ctx context.Context, id int, fields TodoFields, etag string) (*Todo, error) {
+	var todo Todo
+	err := c.do(ctx, &request{method: http.MethodPut, path: todoPath(id), body: fields, header: ifMatch(etag)}, &todo)
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// DeleteTodo moves a todo to the trash. If etag is not empty, the todo is
+// only deleted if it still matches it.
+func (c *Client) DeleteTodo(ctx context.Context, id int, etag string) error {
+	return c.do(ctx, &request{method: http.MethodDelete, path: todoPath(id), header: ifMatch(etag)}, nil)
+}
+
+// SearchTodos returns the todos matching a full-text query, best matches
+// first. A zero limit returns the API's default number of results.
+func (c *Client) SearchTodos(ctx context.Context, q string, limit, offset int) ([]SearchResult, error) {
+	query := url.Values{"q": {q}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+
+	var results []SearchResult
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/todos/search", query: query}, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// BulkTodos applies several todo operations in one request, in mode
+// BulkAtomic or BulkBestEffort. The result of every operation is in the
+// response; the error only reports a failure of the request as a whole.
+func (c *Client) BulkTodos(ctx context.Context, mode string, operations []BulkOperation) (*BulkResponse, error) {
+	var resp BulkResponse
+	req := &request{
+		method: http.MethodPost,
+		path:   "/todos/bulk",
+		body: map[string]interface{}{
+			"mode":       mode,
+			"operations": operations,
+		},
+	}
+	if err := c.do(ctx, req.withIdempotencyKey(), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
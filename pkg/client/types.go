@@ -0,0 +1,190 @@
+package client
+
+import "time"
+
+// Todo statuses.
+const (
+	StatusActive     = "active"
+	StatusInProgress = "in_progress"
+	StatusDone       = "done"
+)
+
+// User is an account of the API.
+type User struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// Tag is a label of the user's todos.
+type Tag struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+	Color  string `json:"color"`
+}
+
+// Recurrence describes how a todo repeats.
+type Recurrence struct {
+	// Frequency is daily, weekly or monthly.
+	Frequency string `json:"frequency"`
+	// Interval repeats the todo every Interval periods; zero means 1.
+	Interval int `json:"interval,omitempty"`
+	// ByWeekday restricts weekly recurrences to the given days (MO, TU, ... SU).
+	ByWeekday []string `json:"by_weekday,omitempty"`
+	// Until and Count end the series at a date or after a number of occurrences.
+	Until *time.Time `json:"until,omitempty"`
+	Count int        `json:"count,omitempty"`
+}
+
+// Todo is a todo as returned by the API.
+type Todo struct {
+	ID              int         `json:"id"`
+	Title           string      `json:"title"`
+	Description     string      `json:"description"`
+	DescriptionHTML string      `json:"description_html,omitempty"`
+	Status          string      `json:"status"`
+	UserID          int         `json:"user_id"`
+	WorkspaceID     *int        `json:"workspace_id,omitempty"`
+	ListID          *int        `json:"list_id,omitempty"`
+	ParentID        *int        `json:"parent_id,omitempty"`
+	DueAt           *time.Time  `json:"due_at,omitempty"`
+	Recurrence      *Recurrence `json:"recurrence,omitempty"`
+	SeriesID        *int        `json:"series_id,omitempty"`
+	Occurrence      *int        `json:"occurrence,omitempty"`
+	Position        float64     `json:"position"`
+	DeletedAt       *time.Time  `json:"deleted_at,omitempty"`
+	DeletedBy       *int        `json:"deleted_by,omitempty"`
+	Version         int         `json:"version"`
+	// ETag identifies this version of the todo, for the ifMatch arguments of
+	// UpdateTodo, ReplaceTodo and DeleteTodo.
+	ETag      string   `json:"etag,omitempty"`
+	Tags      []Tag    `json:"tags"`
+	BlockedBy []int    `json:"blocked_by"`
+	Blocks    []int    `json:"blocks"`
+	Blocked   bool     `json:"blocked"`
+	Progress  *float64 `json:"progress,omitempty"`
+	// Shared is set by ListTodos on todos shared with the user.
+	Shared bool `json:"shared,omitempty"`
+}
+
+// NewTodo holds the fields of a todo to create.
+type NewTodo struct {
+	Title       string      `json:"title"`
+	Description string      `json:"description,omitempty"`
+	ListID      *int        `json:"list_id,omitempty"`
+	DueAt       *time.Time  `json:"due_at,omitempty"`
+	Recurrence  *Recurrence `json:"recurrence,omitempty"`
+}
+
+// TodoFields holds every editable field of a todo, for ReplaceTodo. Nil
+// fields are cleared.
+type TodoFields struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	DueAt       *time.Time `json:"due_at"`
+	ListID      *int       `json:"list_id"`
+}
+
+// TodoUpdate holds the fields of a todo to change, for UpdateTodo. Nil fields
+// are left unchanged; ClearDueAt and ClearListID clear those fields.
+type TodoUpdate struct {
+	Title       *string
+	Description *string
+	Status      *string
+	DueAt       *time.Time
+	ClearDueAt  bool
+	ListID      *int
+	ClearListID bool
+}
+
+// mergePatch returns the update as a JSON Merge Patch of the todo's fields.
+func (u TodoUpdate) mergePatch() map[string]interface{} {
+	patch := map[string]interface{}{}
+	if u.Title != nil {
+		patch["title"] = *u.Title
+	}
+	if u.Description != nil {
+		patch["description"] = *u.Description
+	}
+	if u.Status != nil {
+		patch["status"] = *u.Status
+	}
+	if u.ClearDueAt {
+		patch["due_at"] = nil
+	} else if u.DueAt != nil {
+		patch["due_at"] = u.DueAt
+	}
+	if u.ClearListID {
+		patch["list_id"] = nil
+	} else if u.ListID != nil {
+		patch["list_id"] = *u.ListID
+	}
+	return patch
+}
+
+// Modes of TodoListOptions.Shared, selecting whether the todos shared with the
+// user are listed with their own todos, left out, or listed alone.
+const (
+	SharedInclude = "include"
+	SharedExclude = "exclude"
+	SharedOnly    = "only"
+)
+
+// TodoListOptions filters and pages the todos returned by ListTodos.
+type TodoListOptions struct {
+	// Status returns only todos with this status.
+	Status string
+	// Shared is SharedInclude (the default), SharedExclude or SharedOnly.
+	Shared string
+	// Tags returns only todos with any of these tags, or all of them if TagMode is "all".
+	Tags    []string
+	TagMode string
+	// Limit and Offset select a page of todos. A zero Limit returns every todo.
+	Limit  int
+	Offset int
+}
+
+// SearchResult is a todo matching a search, with the matching text highlighted.
+type SearchResult struct {
+	Todo
+	Rank                 float64 `json:"rank"`
+	Highlight            string  `json:"highlight"`
+	DescriptionHighlight string  `json:"description_highlight,omitempty"`
+	CommentHighlight     string  `json:"comment_highlight,omitempty"`
+}
+
+// Bulk request modes.
+const (
+	BulkAtomic     = "atomic"
+	BulkBestEffort = "best_effort"
+)
+
+// BulkOperation is one change of a bulk request. Op is create, update, status
+// or delete. Todo holds the new todo's fields for create and a JSON Merge
+// Patch of the todo's fields for update.
+type BulkOperation struct {
+	Op      string      `json:"op"`
+	ID      int         `json:"id,omitempty"`
+	Todo    interface{} `json:"todo,omitempty"`
+	Status  string      `json:"status,omitempty"`
+	IfMatch string      `json:"if_match,omitempty"`
+}
+
+// BulkResult is the outcome of one operation of a bulk request.
+type BulkResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     int    `json:"id,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Todo   *Todo  `json:"todo,omitempty"`
+}
+
+// BulkResponse is the outcome of a bulk request.
+type BulkResponse struct {
+	Mode      string       `json:"mode"`
+	Committed bool         `json:"committed"`
+	Results   []BulkResult `json:"results"`
+}
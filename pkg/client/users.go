@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// Login signs in and keeps the token for the following calls. The
+// credentials are kept too, to sign in again when the token expires.
+func (c *Client) Login(ctx context.Context, username, password string) (*User, error) {
+	if username == "" || password == "" {
+		return nil, errNoCredentials
+	}
+
+	var resp struct {
+		User  User   `json:"user"`
+		Token string `json:"token"`
+	}
+	err := c.do(ctx, &request{
+		method:     http.MethodPost,
+		path:       "/login",
+		body:       map[string]string{"username": username, "password": password},
+		anonymous:  true,
+		idempotent: true,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Tokens.SetToken(resp.Token)
+	c.mu.Lock()
+	c.username, c.password = username, password
+	c.mu.Unlock()
+
+	return &resp.User, nil
+}
+
+// Logout forgets the token and credentials of the client.
+func (c *Client) Logout() {
+	c.Tokens.SetToken("")
+	c.mu.Lock()
+	c.username, c.password = "", ""
+	c.mu.Unlock()
+}
+
+// Register creates an account. It does not sign in.
+func (c *Client) Register(ctx context.Context, username, password string) (*User, error) {
+	if username == "" || password == "" {
+		return nil, errNoCredentials
+	}
+
+	var user User
+	req := &request{
+		method:    http.MethodPost,
+		path:      "/register",
+		body:      map[string]string{"username": username, "password": password},
+		anonymous: true,
+	}
+	if err := c.do(ctx, req.withIdempotencyKey(), &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CurrentUser returns the signed in user.
+func (c *Client) CurrentUser(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/user/details"}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
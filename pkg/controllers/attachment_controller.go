@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/proGabby/simple_auth_todo_api/pkg/data/blobstore"
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+// DefaultMaxAttachmentSize is the largest attachment accepted unless configured otherwise.
+const DefaultMaxAttachmentSize = 10 << 20
+
+// allowedAttachmentTypes are the content types accepted for attachments, as
+// detected from the uploaded bytes rather than taken from the client.
+var allowedAttachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+// AttachmentController handles attachment-related HTTP requests.
+type AttachmentController struct {
+	AttachmentStore models.AttachmentStore
+	Blobs           blobstore.BlobStore
+	// MaxSize is the largest attachment accepted, in bytes.
+	MaxSize int64
+}
+
+// NewAttachmentController creates a new AttachmentController instance.
+func NewAttachmentController(attachmentStore models.AttachmentStore, blobs blobstore.BlobStore) *AttachmentController {
+	return &AttachmentController{AttachmentStore: attachmentStore, Blobs: blobs, MaxSize: DefaultMaxAttachmentSize}
+}
+
+// GetAttachments lists the attachments of the todo in the request URL.
+func (c *AttachmentController) GetAttachments(w http.ResponseWriter, r *http.Request) {
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	attachments, err := c.AttachmentStore.GetAttachments(todoID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving attachments",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachments)
+}
+
+// UploadAttachment attaches the file in the "file" field of a multipart form
+// to the todo in the request URL.
+func (c *AttachmentController) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the multipart form, leaving room for the form's own overhead
+	r.Body = http.MaxBytesReader(w, r.Body, c.MaxSize+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.writeTooLarge(w)
+			return
+		}
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "A multipart form with a file field is required",
+		}, http.StatusBadRequest, w)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "A multipart form with a file field is required",
+		}, http.StatusBadRequest, w)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > c.MaxSize {
+		c.writeTooLarge(w)
+		return
+	}
+
+	// Detect the content type from the file contents
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Error reading file",
+		}, http.StatusBadRequest, w)
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(sniff[:n]))
+	if !allowedAttachmentTypes[contentType] {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Unsupported Media Type",
+			"message": "Attachments must be PNG, JPEG, GIF or WebP images, PDFs or plain text",
+		}, http.StatusUnsupportedMediaType, w)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error reading file",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	key, err := newStorageKey(todoID)
+	if err == nil {
+		err = c.Blobs.Put(r.Context(), key, file, header.Size, contentType)
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error storing attachment",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	// The multipart reader has already stripped any directories from the name
+	filename := header.Filename
+	if filename == "" || filename == "." || filename == "/" {
+		filename = "attachment"
+	}
+
+	attachment, err := c.AttachmentStore.CreateAttachment(models.Attachment{
+		TodoID:      todoID,
+		UserID:      user.ID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        header.Size,
+		StorageKey:  key,
+	})
+	if err != nil {
+		// Do not leave the stored contents behind without a record
+		if err := c.Blobs.Delete(r.Context(), key); err != nil {
+			log.Printf("Error deleting blob %s: %v", key, err)
+		}
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error creating attachment",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// DownloadAttachment responds with the contents of the attachment in the request URL.
+func (c *AttachmentController) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := c.getAttachment(r, w)
+	if !ok {
+		return
+	}
+
+	contents, err := c.Blobs.Get(r.Context(), attachment.StorageKey)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving attachment contents",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+	defer contents.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.Copy(w, contents); err != nil {
+		log.Printf("Error sending attachment %d: %v", attachment.ID, err)
+	}
+}
+
+// DeleteAttachment deletes the attachment in the request URL.
+func (c *AttachmentController) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := c.getAttachment(r, w)
+	if !ok {
+		return
+	}
+
+	err := c.AttachmentStore.DeleteAttachment(attachment.TodoID, attachment.ID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Data Error",
+			"message": "Error deleting attachment",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// getAttachment retrieves the attachment in the request URL, writing an error
+// response and returning false if it does not exist.
+func (c *AttachmentController) getAttachment(r *http.Request, w http.ResponseWriter) (*models.Attachment, bool) {
+	vars := mux.Vars(r)
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return nil, false
+	}
+
+	attachmentID, err := strconv.Atoi(vars["attachmentID"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid attachment ID",
+		}, http.StatusBadRequest, w)
+		return nil, false
+	}
+
+	attachment, err := c.AttachmentStore.GetAttachmentByID(todoID, attachmentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Attachment not found"}, http.StatusNotFound, w)
+		return nil, false
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving attachment",
+		}, http.StatusInternalServerError, w)
+		return nil, false
+	}
+
+	return attachment, true
+}
+
+func (c *AttachmentController) writeTooLarge(w http.ResponseWriter) {
+	utils.HandleError(map[string]interface{}{
+		"error":   "Request Entity Too Large",
+		"message": fmt.Sprintf("Attachments must not be larger than %d bytes", c.MaxSize),
+	}, http.StatusRequestEntityTooLarge, w)
+}
+
+// newStorageKey generates an unguessable blob key for an attachment of a todo.
+func newStorageKey(todoID int) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("todos/%d/%s", todoID, hex.EncodeToString(b)), nil
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+const (
+	// defaultAuditPageSize is the number of audit events returned when no limit is given.
+	defaultAuditPageSize = 100
+	// maxAuditPageSize caps the number of audit events returned by a single query.
+	maxAuditPageSize = 1000
+)
+
+// AuditController handles queries of the security audit log.
+type AuditController struct {
+	AuditStore models.AuditStore
+}
+
+// NewAuditController creates a new AuditController instance.
+func NewAuditController(auditStore models.AuditStore) *AuditController {
+	return &AuditController{AuditStore: auditStore}
+}
+
+// GetAuditEvents retrieves a page of the audit events matching the filters in
+// the query string, most recent first.
+func (c *AuditController) GetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditPageSize
+	}
+
+	events, err := c.AuditStore.GetEvents(filter)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving audit events",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// ExportAuditEvents streams the audit events matching the filters in the query
+// string as JSON lines, one event per line, most recent first. Unlike
+// GetAuditEvents the export is not limited unless a limit is given.
+func (c *AuditController) ExportAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	encoder := json.NewEncoder(w)
+	err = c.AuditStore.EachEvent(filter, func(event models.AuditEvent) error {
+		return encoder.Encode(event)
+	})
+	if err != nil {
+		// The response has already started, so the export is cut short
+		log.Printf("Error exporting audit events: %v", err)
+	}
+}
+
+// parseAuditFilter reads an audit filter from the query string: event,
+// outcome, actor_id, ip, since and until (RFC 3339 times), limit and offset.
+func parseAuditFilter(r *http.Request) (models.AuditFilter, error) {
+	var filter models.AuditFilter
+	query := r.URL.Query()
+
+	filter.Event = query.Get("event")
+	filter.Outcome = query.Get("outcome")
+	if filter.Outcome != "" && filter.Outcome != models.AuditSuccess && filter.Outcome != models.AuditFailure {
+		return filter, fmt.Errorf("outcome must be %s or %s", models.AuditSuccess, models.AuditFailure)
+	}
+	filter.IP = query.Get("ip")
+
+	if value := query.Get("actor_id"); value != "" {
+		actorID, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, errors.New("invalid actor_id")
+		}
+		filter.ActorID = actorID
+	}
+
+	for name, dest := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*dest = &t
+		}
+	}
+
+	var err error
+	filter.Limit, filter.Offset, err = parsePage(r, maxAuditPageSize)
+	return filter, err
+}
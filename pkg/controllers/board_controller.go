@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+// BoardController handles board-related HTTP requests.
+type BoardController struct {
+	BoardStore models.BoardStore
+	TodoStore  models.TodoStore
+	ListStore  models.ListStore
+}
+
+// NewBoardController creates a new BoardController instance.
+func NewBoardController(boardStore models.BoardStore, todoStore models.TodoStore, listStore models.ListStore) *BoardController {
+	return &BoardController{BoardStore: boardStore, TodoStore: todoStore, ListStore: listStore}
+}
+
+// GetBoardsByUser retrieves the authenticated user's boards.
+func (c *BoardController) GetBoardsByUser(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	boards, err := c.BoardStore.GetBoardsByUserID(user.ID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving boards",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(boards)
+}
+
+// CreateBoard creates a board over the authenticated user's personal todos, or
+// over one of their lists when list_id is given. Boards created without
+// columns get a column for each todo status.
+func (c *BoardController) CreateBoard(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var newBoard models.Board
+	err := json.NewDecoder(r.Body).Decode(&newBoard)
+	if err != nil || strings.TrimSpace(newBoard.Name) == "" {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "A name is required",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	if newBoard.Columns == nil {
+		newBoard.Columns = models.DefaultBoardColumns
+	}
+	if err := models.ValidateBoardColumns(newBoard.Columns); err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	if newBoard.ListID != nil && !c.checkListOwner(*newBoard.ListID, user, w) {
+		return
+	}
+
+	board, err := c.BoardStore.CreateBoard(user.ID, newBoard.Name, newBoard.ListID, newBoard.Columns)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error creating board",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	c.writeBoard(board, r, w)
+}
+
+// GetSingleBoard retrieves the board in the request URL with the todos in each column.
+func (c *BoardController) GetSingleBoard(w http.ResponseWriter, r *http.Request) {
+	board, ok := c.getOwnedBoard(r, w)
+	if !ok {
+		return
+	}
+
+	c.writeBoard(board, r, w)
+}
+
+// SetBoardColumns replaces the columns of the board in the request URL.
+func (c *BoardController) SetBoardColumns(w http.ResponseWriter, r *http.Request) {
+	board, ok := c.getOwnedBoard(r, w)
+	if !ok {
+		return
+	}
+
+	// Parse the JSON request body
+	var body struct {
+		Columns []models.BoardColumn `json:"columns"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err == nil {
+		err = models.ValidateBoardColumns(body.Columns)
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	board, err = c.BoardStore.SetColumns(board.ID, body.Columns)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error updating board columns",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	c.writeBoard(board, r, w)
+}
+
+// DeleteBoard deletes the board in the request URL, keeping its todos.
+func (c *BoardController) DeleteBoard(w http.ResponseWriter, r *http.Request) {
+	board, ok := c.getOwnedBoard(r, w)
+	if !ok {
+		return
+	}
+
+	err := c.BoardStore.DeleteBoard(board.ID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Data Error",
+			"message": "Error deleting board",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// MoveTodo drags a todo on the board in the request URL into the column given
+// by column_id, taking that column's status. The todo is also placed before or
+// after another todo when before_id or after_id is given.
+func (c *BoardController) MoveTodo(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	board, ok := c.getOwnedBoard(r, w)
+	if !ok {
+		return
+	}
+
+	// Parse the JSON request body
+	var body struct {
+		TodoID   int  `json:"todo_id"`
+		ColumnID int  `json:"column_id"`
+		BeforeID *int `json:"before_id"`
+		AfterID  *int `json:"after_id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.TodoID == 0 || (body.BeforeID != nil && body.AfterID != nil) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "A todo_id, a column_id and at most one of before_id and after_id are required",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	column := board.ColumnByID(body.ColumnID)
+	if column == nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid column ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// The todo must be on the board and editable by the user
+	todo, err := c.TodoStore.GetTodoByID(body.TodoID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+	if err == nil && !board.Includes(todo) {
+		err = sql.ErrNoRows
+	}
+	access := models.TodoAccessNone
+	if err == nil {
+		access, err = c.TodoStore.GetAccessLevel(todo, user.ID)
+		if err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Internal Server Error",
+				"message": "Error checking todo access",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+	}
+	if !models.TodoAccessAtLeast(access, models.TodoAccessEdit) {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Todo not found on this board"}, http.StatusNotFound, w)
+		return
+	}
+
+	referenceID, after := 0, body.AfterID != nil
+	if after {
+		referenceID = *body.AfterID
+	} else if body.BeforeID != nil {
+		referenceID = *body.BeforeID
+	}
+
+	todo, err = c.TodoStore.MoveTodoToColumn(todo.ID, column.Status, referenceID, after, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Todo not found on this board"}, http.StatusNotFound, w)
+		return
+	}
+	if errors.Is(err, models.ErrInvalidMove) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+	if errors.Is(err, models.ErrTodoBlocked) || errors.Is(err, models.ErrWIPLimitReached) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Conflict",
+			"message": err.Error(),
+		}, http.StatusConflict, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error moving todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	writeTodo(r, w, c.TodoStore, todo)
+}
+
+// getOwnedBoard retrieves the board in the request URL, writing an error
+// response and returning false if it does not exist or belongs to someone else.
+func (c *BoardController) getOwnedBoard(r *http.Request, w http.ResponseWriter) (*models.Board, bool) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return nil, false
+	}
+
+	boardID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid board ID",
+		}, http.StatusBadRequest, w)
+		return nil, false
+	}
+
+	board, err := c.BoardStore.GetBoardByID(boardID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving board",
+		}, http.StatusInternalServerError, w)
+		return nil, false
+	}
+
+	if err != nil || board.UserID != user.ID {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Board not found"}, http.StatusNotFound, w)
+		return nil, false
+	}
+
+	return board, true
+}
+
+// checkListOwner verifies that a board's list belongs to the user, writing an
+// error response and returning false if it does not.
+func (c *BoardController) checkListOwner(listID int, user *models.User, w http.ResponseWriter) bool {
+	list, err := c.ListStore.GetListByID(listID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving list",
+		}, http.StatusInternalServerError, w)
+		return false
+	}
+
+	if err != nil || list.UserID != user.ID {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid list ID",
+		}, http.StatusBadRequest, w)
+		return false
+	}
+
+	return true
+}
+
+// writeBoard responds with a board and the todos in each of its columns.
+func (c *BoardController) writeBoard(board *models.Board, r *http.Request, w http.ResponseWriter) {
+	if err := c.TodoStore.LoadBoardTodos(board); err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Data Error",
+			"message": "Error retrieving board todos",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	for i := range board.Columns {
+		if err := hideDependencies(r, c.TodoStore, board.Columns[i].Todos); err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Data Error",
+				"message": "Error retrieving board todos",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(board)
+}
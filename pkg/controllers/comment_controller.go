@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+const (
+	// defaultCommentPageSize is the number of comments returned when no limit is given.
+	defaultCommentPageSize = 50
+	// maxCommentPageSize caps the number of comments returned by a single listing.
+	maxCommentPageSize = 200
+)
+
+// CommentController handles comment-related HTTP requests.
+type CommentController struct {
+	CommentStore models.CommentStore
+}
+
+// NewCommentController creates a new CommentController instance.
+func NewCommentController(commentStore models.CommentStore) *CommentController {
+	return &CommentController{CommentStore: commentStore}
+}
+
+// GetComments retrieves a page of the comments on the todo in the request URL, oldest first.
+func (c *CommentController) GetComments(w http.ResponseWriter, r *http.Request) {
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	limit, offset, err := parsePage(r, maxCommentPageSize)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+	if limit == 0 {
+		limit = defaultCommentPageSize
+	}
+
+	comments, err := c.CommentStore.GetComments(todoID, limit, offset)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving comments",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comments)
+}
+
+// CreateComment adds a comment by the authenticated user to the todo in the request URL.
+func (c *CommentController) CreateComment(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	body, ok := parseCommentBody(r, w)
+	if !ok {
+		return
+	}
+
+	comment, err := c.CommentStore.CreateComment(todoID, user.ID, body)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error creating comment",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+// UpdateComment replaces the body of one of the authenticated user's comments
+// while its edit window is open.
+func (c *CommentController) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	comment, ok := c.getAuthoredComment(r, w)
+	if !ok {
+		return
+	}
+
+	body, ok := parseCommentBody(r, w)
+	if !ok {
+		return
+	}
+
+	comment, err := c.CommentStore.UpdateComment(comment.TodoID, comment.ID, body)
+	if errors.Is(err, models.ErrCommentEditWindowClosed) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Conflict",
+			"message": err.Error(),
+		}, http.StatusConflict, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error updating comment",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+// DeleteComment deletes one of the authenticated user's comments.
+func (c *CommentController) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	comment, ok := c.getAuthoredComment(r, w)
+	if !ok {
+		return
+	}
+
+	err := c.CommentStore.DeleteComment(comment.TodoID, comment.ID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Data Error",
+			"message": "Error deleting comment",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// getAuthoredComment retrieves the comment in the request URL, writing an error
+// response and returning false if it does not exist or was written by someone else.
+func (c *CommentController) getAuthoredComment(r *http.Request, w http.ResponseWriter) (*models.Comment, bool) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return nil, false
+	}
+
+	vars := mux.Vars(r)
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return nil, false
+	}
+
+	commentID, err := strconv.Atoi(vars["commentID"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid comment ID",
+		}, http.StatusBadRequest, w)
+		return nil, false
+	}
+
+	comment, err := c.CommentStore.GetCommentByID(todoID, commentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Comment not found"}, http.StatusNotFound, w)
+		return nil, false
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving comment",
+		}, http.StatusInternalServerError, w)
+		return nil, false
+	}
+
+	if comment.UserID != user.ID {
+		utils.HandleError(map[string]interface{}{"error": "Forbidden", "message": "Only the author can change a comment"}, http.StatusForbidden, w)
+		return nil, false
+	}
+
+	return comment, true
+}
+
+// parseCommentBody reads the comment body from the JSON request body, writing
+// an error response and returning false if it is empty or too long.
+func parseCommentBody(r *http.Request, w http.ResponseWriter) (string, bool) {
+	var body struct {
+		Body string `json:"body"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || strings.TrimSpace(body.Body) == "" || utf8.RuneCountInString(body.Body) > models.MaxCommentLength {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": fmt.Sprintf("A body of at most %d characters is required", models.MaxCommentLength),
+		}, http.StatusBadRequest, w)
+		return "", false
+	}
+
+	return body.Body, true
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"io/fs"
+	"net/http"
+)
+
+// DocsController serves the API documentation.
+type DocsController struct {
+	Spec []byte
+	UI   fs.FS
+}
+
+// NewDocsController creates a new DocsController instance.
+func NewDocsController(spec []byte, ui fs.FS) *DocsController {
+	return &DocsController{Spec: spec, UI: ui}
+}
+
+// GetSpec responds with the OpenAPI document of the API.
+func (c *DocsController) GetSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(c.Spec)
+}
+
+// GetUI serves the documentation page and its assets under /docs/.
+func (c *DocsController) GetUI(w http.ResponseWriter, r *http.Request) {
+	http.StripPrefix("/docs/", http.FileServer(http.FS(c.UI))).ServeHTTP(w, r)
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+// ListController handles list-related HTTP requests.
+type ListController struct {
+	ListStore models.ListStore
+}
+
+// NewListController creates a new ListController instance.
+func NewListController(listStore models.ListStore) *ListController {
+	return &ListController{ListStore: listStore}
+}
+
+// GetListsByUser retrieves the authenticated user's lists. Archived lists are
+// included when the archived query parameter is true.
+func (c *ListController) GetListsByUser(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	includeArchived := r.URL.Query().Get("archived") == "true"
+
+	lists, err := c.ListStore.GetListsByUserID(user.ID, includeArchived)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving lists",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lists)
+}
+
+// CreateList creates a new list for the authenticated user.
+func (c *ListController) CreateList(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var newList models.List
+	err := json.NewDecoder(r.Body).Decode(&newList)
+	if err != nil || strings.TrimSpace(newList.Name) == "" || !models.IsValidColor(newList.Color) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "A name and an optional #rrggbb color are required",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	createdList, err := c.ListStore.CreateList(user.ID, newList.Name, newList.Color)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error creating list",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(createdList)
+}
+
+// GetSingleList retrieves the list in the request URL.
+func (c *ListController) GetSingleList(w http.ResponseWriter, r *http.Request) {
+	listID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid list ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	list, err := c.ListStore.GetListByID(listID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Data Error",
+			"message": "Error retrieving list",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// UpdateList updates the name, color, archived flag or position of the list in the request URL.
+func (c *ListController) UpdateList(w http.ResponseWriter, r *http.Request) {
+	listID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid list ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var update models.ListUpdate
+	err = json.NewDecoder(r.Body).Decode(&update)
+	if err != nil ||
+		(update.Name != nil && strings.TrimSpace(*update.Name) == "") ||
+		(update.Color != nil && !models.IsValidColor(*update.Color)) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid request body",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	list, err := c.ListStore.UpdateList(listID, update)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error updating list",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// DeleteList deletes the list in the request URL, keeping its todos.
+func (c *ListController) DeleteList(w http.ResponseWriter, r *http.Request) {
+	listID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid list ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	err = c.ListStore.DeleteList(listID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Data Error",
+			"message": "Error deleting list",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
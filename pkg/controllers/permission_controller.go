@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/proGabby/simple_auth_todo_api/pkg/middlewares"
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+// PermissionController handles the administration of role permissions and user roles.
+type PermissionController struct {
+	PermissionStore models.PermissionStore
+	UserStore       models.UserStore
+	AuditStore      models.AuditStore
+}
+
+// NewPermissionController creates a new PermissionController instance.
+func NewPermissionController(permissionStore models.PermissionStore, userStore models.UserStore, auditStore models.AuditStore) *PermissionController {
+	return &PermissionController{PermissionStore: permissionStore, UserStore: userStore, AuditStore: auditStore}
+}
+
+// GetRolePermissions lists the known permissions and the permissions granted to each role.
+func (c *PermissionController) GetRolePermissions(w http.ResponseWriter, r *http.Request) {
+	rolePermissions, err := c.PermissionStore.GetRolePermissions()
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving role permissions",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"permissions": models.AllPermissions,
+		"roles":       rolePermissions,
+	})
+}
+
+// SetRolePermissions replaces the permissions granted to the role in the request URL.
+func (c *PermissionController) SetRolePermissions(w http.ResponseWriter, r *http.Request) {
+	role := mux.Vars(r)["role"]
+
+	// Parse the JSON request body
+	var body struct {
+		Permissions []string `json:"permissions"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid request body",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	for _, permission := range body.Permissions {
+		if !models.IsValidPermission(permission) {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Bad Request",
+				"message": "Unknown permission " + permission,
+			}, http.StatusBadRequest, w)
+			return
+		}
+	}
+
+	err = c.PermissionStore.SetRolePermissions(role, body.Permissions)
+	event := models.AuditEvent{
+		Event:   models.AuditAdminRolePermission,
+		Outcome: models.AuditSuccess,
+		Target:  role,
+		Details: map[string]interface{}{"permissions": body.Permissions},
+	}
+	if err != nil {
+		event.Outcome = models.AuditFailure
+	}
+	middlewares.RecordAudit(c.AuditStore, r, event)
+	if errors.Is(err, models.ErrLastAdmin) {
+		utils.HandleError(map[string]interface{}{"error": "Conflict", "message": err.Error()}, http.StatusConflict, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error updating role permissions",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	permissions, err := c.PermissionStore.GetPermissionsForRole(role)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving role permissions",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"role":        role,
+		"permissions": permissions,
+	})
+}
+
+// SetUserRole changes the role of the user in the request URL.
+func (c *PermissionController) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	// Parse user ID from the request URL
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid user ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var body struct {
+		Role string `json:"role"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.Role == "" {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "A role is required",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Only roles with a permission mapping can be assigned
+	rolePermissions, err := c.PermissionStore.GetRolePermissions()
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving role permissions",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+	if _, ok := rolePermissions[body.Role]; !ok {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Unknown role " + body.Role,
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	user, previousRole, err := c.UserStore.SetUserRole(userID, body.Role)
+	event := models.AuditEvent{
+		Event:   models.AuditAdminUserRole,
+		Outcome: models.AuditSuccess,
+		Target:  "user:" + strconv.Itoa(userID),
+		Details: map[string]interface{}{"previous_role": previousRole, "role": body.Role},
+	}
+	if err != nil {
+		event.Outcome = models.AuditFailure
+		event.Details = map[string]interface{}{"role": body.Role}
+	}
+	middlewares.RecordAudit(c.AuditStore, r, event)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "User not found"}, http.StatusNotFound, w)
+		return
+	}
+	if errors.Is(err, models.ErrLastAdmin) {
+		utils.HandleError(map[string]interface{}{"error": "Conflict", "message": err.Error()}, http.StatusConflict, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error updating user role",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
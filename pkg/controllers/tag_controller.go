@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+// TagController handles tag-related HTTP requests.
+type TagController struct {
+	TagStore  models.TagStore
+	TodoStore models.TodoStore
+}
+
+// NewTagController creates a new TagController instance.
+func NewTagController(tagStore models.TagStore, todoStore models.TodoStore) *TagController {
+	return &TagController{TagStore: tagStore, TodoStore: todoStore}
+}
+
+// GetTagsByUser retrieves the authenticated user's tags.
+func (c *TagController) GetTagsByUser(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	tags, err := c.TagStore.GetTagsByUserID(user.ID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving tags",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// CreateTag creates a new tag for the authenticated user.
+func (c *TagController) CreateTag(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var newTag models.Tag
+	err := json.NewDecoder(r.Body).Decode(&newTag)
+	if err != nil || !isValidTagName(newTag.Name) || !models.IsValidColor(newTag.Color) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "A name without commas and an optional #rrggbb color are required",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	createdTag, err := c.TagStore.CreateTag(user.ID, strings.TrimSpace(newTag.Name), newTag.Color)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error creating tag",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(createdTag)
+}
+
+// DeleteTag deletes one of the authenticated user's tags.
+func (c *TagController) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	tagID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid tag ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	if _, ok := c.getOwnedTag(tagID, user, w); !ok {
+		return
+	}
+
+	err = c.TagStore.DeleteTag(tagID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Data Error",
+			"message": "Error deleting tag",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// AttachTag attaches one of the authenticated user's tags to the todo in the
+// request URL. The tag is given by tag_id or by name, creating it if needed.
+func (c *TagController) AttachTag(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var body struct {
+		TagID int    `json:"tag_id"`
+		Name  string `json:"name"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || (body.TagID == 0 && !isValidTagName(body.Name)) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "A tag_id or a tag name is required",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	var tag *models.Tag
+	if body.TagID != 0 {
+		if tag, ok = c.getOwnedTag(body.TagID, user, w); !ok {
+			return
+		}
+	} else {
+		tag, err = c.TagStore.CreateTag(user.ID, strings.TrimSpace(body.Name), "")
+		if err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Internal Server Error",
+				"message": "Error creating tag",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+	}
+
+	err = c.TagStore.AttachTag(todoID, tag.ID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error attaching tag",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	c.writeTodo(todoID, r, w)
+}
+
+// DetachTag removes the tag in the request URL from the todo in the request URL.
+func (c *TagController) DetachTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	tagID, err := strconv.Atoi(vars["tagID"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid tag ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	err = c.TagStore.DetachTag(todoID, tagID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error detaching tag",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	c.writeTodo(todoID, r, w)
+}
+
+// getOwnedTag retrieves a tag owned by the user, writing an error response and
+// returning false if it does not exist or belongs to someone else.
+func (c *TagController) getOwnedTag(tagID int, user *models.User, w http.ResponseWriter) (*models.Tag, bool) {
+	tag, err := c.TagStore.GetTagByID(tagID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving tag",
+		}, http.StatusInternalServerError, w)
+		return nil, false
+	}
+
+	if err != nil || tag.UserID != user.ID {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Tag not found"}, http.StatusNotFound, w)
+		return nil, false
+	}
+
+	return tag, true
+}
+
+// writeTodo responds with the current state of a todo.
+func (c *TagController) writeTodo(todoID int, r *http.Request, w http.ResponseWriter) {
+	todo, err := c.TodoStore.GetTodoByID(todoID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Data Error",
+			"message": "Error retrieving todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	writeTodo(r, w, c.TodoStore, todo)
+}
+
+// isValidTagName reports whether name can be used as a tag name. Commas are
+// rejected because the tags filter is a comma separated list.
+func isValidTagName(name string) bool {
+	return strings.TrimSpace(name) != "" && !strings.Contains(name, ",")
+}
@@ -0,0 +1,334 @@
+package controllers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+const (
+	// maxBulkOperations caps the number of operations in a single bulk request.
+	maxBulkOperations = 100
+
+	// In atomic mode a bulk request is applied only if every operation
+	// succeeds; in best-effort mode failed operations are skipped.
+	bulkModeAtomic     = "atomic"
+	bulkModeBestEffort = "best_effort"
+)
+
+// bulkOperation is one change in a bulk request. Op is create, update, status
+// or delete. Creates take the new todo's fields in Todo, updates a JSON Merge
+// Patch of its fields in Todo, and status changes the new Status. IfMatch
+// holds an ETag the todo must still match, like the If-Match header.
+type bulkOperation struct {
+	Op      string          `json:"op"`
+	ID      int             `json:"id"`
+	Todo    json.RawMessage `json:"todo"`
+	Status  string          `json:"status"`
+	IfMatch string          `json:"if_match"`
+}
+
+// bulkResult reports the outcome of one operation of a bulk request with the
+// status code the equivalent single-todo request would have returned.
+type bulkResult struct {
+	Index  int          `json:"index"`
+	Op     string       `json:"op"`
+	ID     int          `json:"id,omitempty"`
+	Status int          `json:"status"`
+	Error  string       `json:"error,omitempty"`
+	Todo   *models.Todo `json:"todo,omitempty"`
+}
+
+// bulkError is an operation failure with the status code to report for it.
+type bulkError struct {
+	status  int
+	message string
+}
+
+func (e *bulkError) Error() string { return e.message }
+
+// BulkTodos applies a list of todo operations in one transaction. Each
+// operation is subject to the same permission and access checks as the
+// corresponding single-todo route. In atomic mode (the default) nothing is
+// applied if any operation fails; in best-effort mode the operations that
+// succeed are applied and the others are reported as failed.
+func (c *TodoController) BulkTodos(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var body struct {
+		Mode       string          `json:"mode"`
+		Operations []bulkOperation `json:"operations"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid request body",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	if body.Mode == "" {
+		body.Mode = bulkModeAtomic
+	}
+	if body.Mode != bulkModeAtomic && body.Mode != bulkModeBestEffort {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": fmt.Sprintf("mode must be %s or %s", bulkModeAtomic, bulkModeBestEffort),
+		}, http.StatusBadRequest, w)
+		return
+	}
+	if len(body.Operations) == 0 || len(body.Operations) > maxBulkOperations {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": fmt.Sprintf("between 1 and %d operations are required", maxBulkOperations),
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	granted, err := c.PermissionStore.GetPermissionsForRole(user.Role)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error checking permissions",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	batch, err := c.TodoStore.BeginBatch()
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error starting bulk operation",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+	defer batch.Rollback()
+
+	// Apply the operations, stopping at the first failure in atomic mode
+	results := make([]bulkResult, len(body.Operations))
+	failed := -1
+	for i, op := range body.Operations {
+		results[i] = bulkResult{Index: i, Op: op.Op, ID: op.ID, Status: http.StatusOK}
+
+		todo, err := c.applyBulkOperation(batch, op, user, granted)
+		if err != nil {
+			results[i].Status, results[i].Error = bulkErrorStatus(err)
+			if body.Mode == bulkModeAtomic {
+				failed = i
+				break
+			}
+			continue
+		}
+		if todo != nil {
+			results[i].ID = todo.ID
+			results[i].Todo = todo
+		}
+	}
+
+	committed := failed < 0
+	if committed {
+		if err := batch.Commit(); err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Internal Server Error",
+				"message": "Error applying bulk operation",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+
+		if err := c.loadBulkTodos(r, results); err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Internal Server Error",
+				"message": "Error retrieving todos",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+	} else {
+		for i := range results {
+			switch {
+			case i < failed:
+				results[i].Status = http.StatusFailedDependency
+				results[i].Error = fmt.Sprintf("rolled back because operation %d failed", failed)
+				results[i].Todo = nil
+			case i > failed:
+				op := body.Operations[i]
+				results[i] = bulkResult{Index: i, Op: op.Op, ID: op.ID, Status: http.StatusFailedDependency,
+					Error: fmt.Sprintf("not attempted because operation %d failed", failed)}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mode":      body.Mode,
+		"committed": committed,
+		"results":   results,
+	})
+}
+
+// applyBulkOperation checks and applies one operation of a bulk request as
+// part of batch, returning the created or changed todo, if any.
+func (c *TodoController) applyBulkOperation(batch *models.TodoBatch, op bulkOperation, user *models.User, granted []string) (*models.Todo, error) {
+	required := models.PermTodoWrite
+	if op.Op == "delete" {
+		required = models.PermTodoDelete
+	}
+	if !containsString(granted, required) {
+		return nil, &bulkError{http.StatusForbidden, "You are not permitted"}
+	}
+
+	var ifVersions []int
+	if op.Op != "create" {
+		if c.RequireIfMatch && op.IfMatch == "" {
+			return nil, &bulkError{http.StatusPreconditionRequired, "An if_match with the todo's ETag is required"}
+		}
+		ifVersions = parseIfMatch(op.IfMatch)
+
+		if err := c.checkBulkAccess(op.ID, user, granted); err != nil {
+			return nil, err
+		}
+	}
+
+	var todo *models.Todo
+	var err error
+	switch op.Op {
+	case "create":
+		var fields models.TodoFields
+		decoder := json.NewDecoder(bytes.NewReader(op.Todo))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&fields); err != nil {
+			return nil, &bulkError{http.StatusBadRequest, "Invalid todo: " + err.Error()}
+		}
+		if fields.Status == "" {
+			fields.Status = models.TodoStatusActive
+		}
+		if err := c.checkTodoFields(models.TodoFields{}, fields, user); err != nil {
+			return nil, err
+		}
+
+		err = batch.Apply(func() (err error) {
+			todo, err = batch.CreateTodo(models.Todo{
+				Title:       fields.Title,
+				Description: fields.Description,
+				Status:      fields.Status,
+				UserID:      user.ID,
+				ListID:      fields.ListID,
+				DueAt:       fields.DueAt,
+			})
+			return err
+		})
+	case "update":
+		if len(op.Todo) == 0 {
+			return nil, &bulkError{http.StatusBadRequest, "An update requires a todo patch"}
+		}
+		edit := c.patchEdit(utils.ApplyMergePatch, op.Todo, user)
+		err = batch.Apply(func() (err error) {
+			todo, err = batch.EditTodo(op.ID, edit, user.ID, ifVersions)
+			return err
+		})
+	case "status":
+		if op.Status == "" {
+			return nil, &bulkError{http.StatusBadRequest, "A status change requires a status"}
+		}
+		err = batch.Apply(func() (err error) {
+			todo, err = batch.EditTodo(op.ID, func(fields models.TodoFields) (models.TodoFields, error) {
+				fields.Status = op.Status
+				return fields, nil
+			}, user.ID, ifVersions)
+			return err
+		})
+	case "delete":
+		err = batch.Apply(func() error {
+			return batch.DeleteTodo(op.ID, user.ID, ifVersions)
+		})
+	default:
+		return nil, &bulkError{http.StatusBadRequest, fmt.Sprintf("Unknown operation %q", op.Op)}
+	}
+
+	return todo, err
+}
+
+// checkBulkAccess verifies that the user may edit the todo with the given ID,
+// as AuthorizeTodo does for single-todo routes.
+func (c *TodoController) checkBulkAccess(todoID int, user *models.User, granted []string) error {
+	todo, err := c.TodoStore.GetTodoByID(todoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &bulkError{http.StatusNotFound, "Todo not found"}
+	}
+	if err != nil {
+		return err
+	}
+
+	access, err := c.TodoStore.GetAccessLevel(todo, user.ID)
+	if err != nil {
+		return err
+	}
+	if !models.TodoAccessAtLeast(access, models.TodoAccessEdit) && !containsString(granted, models.PermTodoManage) {
+		return &bulkError{http.StatusForbidden, "You are not permitted"}
+	}
+
+	return nil
+}
+
+// loadBulkTodos fills in the related data and ETags of the todos in results.
+func (c *TodoController) loadBulkTodos(r *http.Request, results []bulkResult) error {
+	var todos []models.Todo
+	for _, result := range results {
+		if result.Todo != nil {
+			todos = append(todos, *result.Todo)
+		}
+	}
+
+	if err := c.TodoStore.LoadTodos(todos); err != nil {
+		return err
+	}
+	if err := hideDependencies(r, c.TodoStore, todos); err != nil {
+		return err
+	}
+	if err := setTodoETags(todos); err != nil {
+		return err
+	}
+
+	for i := range results {
+		if results[i].Todo != nil {
+			results[i].Todo = &todos[0]
+			todos = todos[1:]
+		}
+	}
+
+	return nil
+}
+
+// bulkErrorStatus maps the error of a bulk operation to a status code and message.
+func bulkErrorStatus(err error) (int, string) {
+	var opErr *bulkError
+	if errors.As(err, &opErr) {
+		return opErr.status, opErr.message
+	}
+
+	return editErrorStatus(err, http.StatusBadRequest)
+}
+
+// containsString reports whether values includes value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -1,27 +1,338 @@
 package controllers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/mux"
 	"github.com/proGabby/simple_auth_todo_api/pkg/models"
 	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
 )
 
+const (
+	// maxTodoPageSize caps the number of todos returned by a single listing.
+	maxTodoPageSize = 100
+	// defaultSearchPageSize is the number of search results returned when no limit is given.
+	defaultSearchPageSize = 20
+	// maxPatchSize is the largest patch accepted by PatchTodo, in bytes.
+	maxPatchSize = 1 << 20
+	// acceptedPatchTypes are the patch formats accepted by PatchTodo.
+	acceptedPatchTypes = "application/merge-patch+json, application/json-patch+json"
+	// defaultHistoryPageSize is the number of revisions returned when no limit is given.
+	defaultHistoryPageSize = 50
+)
+
 // TodoController handles todo-related HTTP requests.
 type TodoController struct {
-	TodoStore models.TodoStore
+	TodoStore       models.TodoStore
+	ListStore       models.ListStore
+	PermissionStore models.PermissionStore
+	// RequireIfMatch rejects updates and deletions without an If-Match header.
+	RequireIfMatch bool
 }
 
 // NewTodoController creates a new TodoController instance.
-func NewTodoController(todoStore models.TodoStore) *TodoController {
-	return &TodoController{TodoStore: todoStore}
+func NewTodoController(todoStore models.TodoStore, listStore models.ListStore, permissionStore models.PermissionStore) *TodoController {
+	return &TodoController{TodoStore: todoStore, ListStore: listStore, PermissionStore: permissionStore}
+}
+
+// parseTodoFilter reads the filtering and paging query parameters shared by every todo listing.
+func parseTodoFilter(r *http.Request) (models.TodoFilter, error) {
+	query := r.URL.Query()
+	filter := models.TodoFilter{Status: query.Get("status")}
+
+	if tags := query.Get("tags"); tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				filter.Tags = append(filter.Tags, tag)
+			}
+		}
+	}
+
+	switch mode := query.Get("tag_mode"); mode {
+	case "", models.TagModeAny:
+		filter.TagMode = models.TagModeAny
+	case models.TagModeAll:
+		filter.TagMode = models.TagModeAll
+	default:
+		return filter, errors.New("tag_mode must be any or all")
+	}
+
+	var err error
+	filter.Limit, filter.Offset, err = parsePage(r, maxTodoPageSize)
+	return filter, err
+}
+
+// parsePage reads the limit and offset query parameters, leaving them 0 when absent.
+func parsePage(r *http.Request, maxLimit int) (limit, offset int, err error) {
+	query := r.URL.Query()
+
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+	}
+
+	if value := query.Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// checkDescription verifies that a todo description is within
+// MaxDescriptionLength, writing an error response and returning false if not.
+func checkDescription(description string, w http.ResponseWriter) bool {
+	if utf8.RuneCountInString(description) > models.MaxDescriptionLength {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": fmt.Sprintf("description must be at most %d characters", models.MaxDescriptionLength),
+		}, http.StatusBadRequest, w)
+		return false
+	}
+
+	return true
+}
+
+// renderDescriptions fills in the HTML description of todos when the request
+// asks for it with render=html.
+func renderDescriptions(r *http.Request, todos []models.Todo) error {
+	if r.URL.Query().Get("render") != "html" {
+		return nil
+	}
+
+	for i := range todos {
+		rendered, err := utils.RenderMarkdown(todos[i].Description)
+		if err != nil {
+			return err
+		}
+		todos[i].DescriptionHTML = rendered
+	}
+
+	return nil
+}
+
+// hideDependencies removes the dependencies the authenticated user cannot read
+// from todos about to be sent.
+func hideDependencies(r *http.Request, store models.TodoStore, todos []models.Todo) error {
+	userID := 0
+	if user, ok := r.Context().Value("user").(*models.User); ok && user != nil {
+		userID = user.ID
+	}
+
+	return store.HideUnreadableDependencies(todos, userID)
+}
+
+// writeTodo responds with a todo, rendering its description if requested, or
+// with 304 Not Modified if its ETag matches the request's If-None-Match.
+func writeTodo(r *http.Request, w http.ResponseWriter, store models.TodoStore, todo *models.Todo) {
+	todos := []models.Todo{*todo}
+	if err := hideDependencies(r, store, todos); err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error checking todo dependencies",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	if err := renderDescriptions(r, todos); err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error rendering description",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	if err := setTodoETags(todos); err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error encoding todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("ETag", todos[0].ETag)
+	if notModified(r, todos[0].ETag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(todos[0])
+}
+
+// writeTodoList responds with todos, rendering their descriptions if
+// requested. Each todo carries its own ETag, and the list as a whole is tagged
+// for If-None-Match.
+func writeTodoList(r *http.Request, w http.ResponseWriter, store models.TodoStore, todos []models.Todo) {
+	if err := hideDependencies(r, store, todos); err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error checking todo dependencies",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	if err := renderDescriptions(r, todos); err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error rendering descriptions",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	var body []byte
+	err := setTodoETags(todos)
+	if err == nil {
+		body, err = json.Marshal(todos)
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error encoding todos",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := fmt.Sprintf(`"%x"`, sum[:8])
+	w.Header().Set("ETag", etag)
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
+
+// setTodoETags fills in the ETag of todos as they are about to be sent. An ETag
+// is made of the todo's version, which If-Match is checked against, and a hash
+// of its representation, which also changes with its tags, dependencies,
+// subtasks and rendering.
+func setTodoETags(todos []models.Todo) error {
+	for i := range todos {
+		todos[i].ETag = ""
+		b, err := json.Marshal(todos[i])
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(b)
+		todos[i].ETag = fmt.Sprintf(`"%d-%x"`, todos[i].Version, sum[:8])
+	}
+
+	return nil
+}
+
+// notModified reports whether a GET or HEAD request's If-None-Match header
+// matches etag, using the weak comparison the header calls for.
+func notModified(r *http.Request, etag string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ifMatchVersions parses the todo versions from a request's If-Match header
+// with parseIfMatch. It writes an error response and returns false if the
+// header is required but missing.
+func (c *TodoController) ifMatchVersions(r *http.Request, w http.ResponseWriter) ([]int, bool) {
+	header := r.Header.Get("If-Match")
+	if c.RequireIfMatch && strings.TrimSpace(header) == "" {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Precondition Required",
+			"message": "An If-Match header with the todo's ETag is required",
+		}, http.StatusPreconditionRequired, w)
+		return nil, false
+	}
+
+	return parseIfMatch(header), true
+}
+
+// parseIfMatch parses the todo versions from an If-Match value. It returns
+// nil, which skips the version check, if the value is empty or "*", and an
+// empty slice, which matches no version, if no tag is valid. Weak tags never match.
+func parseIfMatch(header string) []int {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil
+	}
+
+	versions := []int{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+			continue
+		}
+		version, _, _ := strings.Cut(strings.Trim(tag, `"`), "-")
+		if v, err := strconv.Atoi(version); err == nil {
+			versions = append(versions, v)
+		}
+	}
+
+	return versions
+}
+
+// writePreconditionFailed responds that a todo has changed since the version in If-Match.
+func writePreconditionFailed(w http.ResponseWriter) {
+	utils.HandleError(map[string]interface{}{
+		"error":   "Precondition Failed",
+		"message": models.ErrVersionMismatch.Error(),
+	}, http.StatusPreconditionFailed, w)
+}
+
+// checkListOwner verifies that the list a todo is being placed in belongs to
+// the user, writing an error response and returning false if it does not.
+func (c *TodoController) checkListOwner(listID *int, user *models.User, w http.ResponseWriter) bool {
+	if listID == nil {
+		return true
+	}
+
+	list, err := c.ListStore.GetListByID(*listID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving list",
+		}, http.StatusInternalServerError, w)
+		return false
+	}
+
+	if err != nil || list.UserID != user.ID {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid list ID",
+		}, http.StatusBadRequest, w)
+		return false
+	}
+
+	return true
 }
 
-// GetTodosByUser retrieves all todos for a specific user.
+// GetTodosByUser retrieves the personal todos of the authenticated user and,
+// as selected by the shared query parameter, the todos shared with them.
 func (c *TodoController) GetTodosByUser(w http.ResponseWriter, r *http.Request) {
 	// Extract user ID from the request context that was set during authentication
 	user, ok := r.Context().Value("user").(*models.User)
@@ -32,8 +343,30 @@ func (c *TodoController) GetTodosByUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	filter, err := parseTodoFilter(r)
+	if err == nil {
+		// Todos shared with the user are listed with their own unless excluded
+		switch shared := r.URL.Query().Get("shared"); shared {
+		case "", models.SharedInclude:
+			filter.Shared = models.SharedInclude
+		case "exclude":
+			filter.Shared = models.SharedExclude
+		case models.SharedOnly:
+			filter.Shared = models.SharedOnly
+		default:
+			err = errors.New("shared must be include, exclude or only")
+		}
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+
 	// Retrieve todos for the user
-	todos, err := c.TodoStore.GetTodosByUserID(user.ID)
+	todos, err := c.TodoStore.GetTodosByUserID(user.ID, filter)
 	if err != nil {
 		fmt.Print(err)
 		utils.HandleError(map[string]interface{}{
@@ -44,8 +377,7 @@ func (c *TodoController) GetTodosByUser(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Return todos in the response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(todos)
+	writeTodoList(r, w, c.TodoStore, todos)
 }
 
 // CreateTodo creates a new todo for the authenticated user.
@@ -74,8 +406,20 @@ func (c *TodoController) CreateTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkDescription(newTodo.Description, w) || !c.checkListOwner(newTodo.ListID, user, w) || !checkRecurrence(newTodo.Recurrence, w) {
+		return
+	}
+
 	// Create the todo
-	createdTodo, err := c.TodoStore.CreateTodo(user.ID, newTodo.Title, "active")
+	createdTodo, err := c.TodoStore.CreateTodo(models.Todo{
+		Title:       newTodo.Title,
+		Description: newTodo.Description,
+		Status:      models.TodoStatusActive,
+		UserID:      user.ID,
+		ListID:      newTodo.ListID,
+		DueAt:       newTodo.DueAt,
+		Recurrence:  newTodo.Recurrence,
+	})
 	if err != nil {
 		utils.HandleError(map[string]interface{}{
 			"error":   "Internal Server Error",
@@ -85,11 +429,13 @@ func (c *TodoController) CreateTodo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Return the created todo in the response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(createdTodo)
+	writeTodo(r, w, c.TodoStore, createdTodo)
 }
 
-// UpdateTodo updates an existing todo for the authenticated user.
+// UpdateTodo updates an existing todo for the authenticated user. Empty fields
+// are left unchanged.
+//
+// Deprecated: use ReplaceTodo or PatchTodo, which can also clear fields.
 func (c *TodoController) UpdateTodo(w http.ResponseWriter, r *http.Request) {
 	// Extract user ID from the request context (assuming it was set during authentication)
 	user, ok := r.Context().Value("user").(*models.User)
@@ -111,7 +457,11 @@ func (c *TodoController) UpdateTodo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse the JSON request body
-	var updatedTodo models.Todo
+	var updatedTodo struct {
+		Title       string  `json:"title"`
+		Status      string  `json:"status"`
+		Description *string `json:"description"`
+	}
 	err = json.NewDecoder(r.Body).Decode(&updatedTodo)
 	if err != nil {
 		utils.HandleError(map[string]interface{}{
@@ -121,8 +471,28 @@ func (c *TodoController) UpdateTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if updatedTodo.Description != nil && !checkDescription(*updatedTodo.Description, w) {
+		return
+	}
+
+	ifVersions, ok := c.ifMatchVersions(r, w)
+	if !ok {
+		return
+	}
+
 	// Update the todo
-	newUpdatedTodo, err := c.TodoStore.UpdateTodo(todoID, updatedTodo.Title, updatedTodo.Status, user.ID)
+	newUpdatedTodo, err := c.TodoStore.UpdateTodo(todoID, updatedTodo.Title, updatedTodo.Status, updatedTodo.Description, user.ID, ifVersions)
+	if errors.Is(err, models.ErrVersionMismatch) {
+		writePreconditionFailed(w)
+		return
+	}
+	if errors.Is(err, models.ErrTodoBlocked) || errors.Is(err, models.ErrWIPLimitReached) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Conflict",
+			"message": err.Error(),
+		}, http.StatusConflict, w)
+		return
+	}
 	if err != nil {
 		utils.HandleError(map[string]interface{}{
 			"error":   "Internal Server Error",
@@ -132,8 +502,208 @@ func (c *TodoController) UpdateTodo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Return the updated todo in the response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(newUpdatedTodo)
+	writeTodo(r, w, c.TodoStore, newUpdatedTodo)
+}
+
+// ReplaceTodo sets every directly edited field of the todo in the request URL
+// to the values in the request body. Fields left out of the body are cleared.
+func (c *TodoController) ReplaceTodo(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var fields models.TodoFields
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&fields); err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid request body: " + err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	ifVersions, ok := c.ifMatchVersions(r, w)
+	if !ok {
+		return
+	}
+
+	todo, err := c.TodoStore.EditTodo(todoID, func(current models.TodoFields) (models.TodoFields, error) {
+		return fields, c.checkTodoFields(current, fields, user)
+	}, user.ID, ifVersions)
+	if err != nil {
+		writeEditError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	writeTodo(r, w, c.TodoStore, todo)
+}
+
+// PatchTodo applies the patch in the request body to the directly edited
+// fields of the todo in the request URL. The body is a JSON Merge Patch (RFC
+// 7386), sent as application/merge-patch+json or application/json, or a JSON
+// Patch (RFC 6902), sent as application/json-patch+json. Both are applied to
+// an object of the fields as returned by GetSingleTodo.
+func (c *TodoController) PatchTodo(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Pick the patch format from the content type
+	var applyPatch func(doc, patch []byte) ([]byte, error)
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch contentType {
+	case "application/merge-patch+json", "application/json":
+		applyPatch = utils.ApplyMergePatch
+	case "application/json-patch+json":
+		applyPatch = utils.ApplyJSONPatch
+	default:
+		w.Header().Set("Accept-Patch", acceptedPatchTypes)
+		utils.HandleError(map[string]interface{}{
+			"error":   "Unsupported Media Type",
+			"message": "Patches must be sent as " + acceptedPatchTypes,
+		}, http.StatusUnsupportedMediaType, w)
+		return
+	}
+
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid request body",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	ifVersions, ok := c.ifMatchVersions(r, w)
+	if !ok {
+		return
+	}
+
+	todo, err := c.TodoStore.EditTodo(todoID, c.patchEdit(applyPatch, patch, user), user.ID, ifVersions)
+	if err != nil {
+		writeEditError(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	writeTodo(r, w, c.TodoStore, todo)
+}
+
+// patchEdit returns an edit for TodoStore.EditTodo that applies patch to the
+// fields of a todo with applyPatch and validates the result.
+func (c *TodoController) patchEdit(applyPatch func(doc, patch []byte) ([]byte, error), patch []byte, user *models.User) func(models.TodoFields) (models.TodoFields, error) {
+	return func(current models.TodoFields) (models.TodoFields, error) {
+		var fields models.TodoFields
+		doc, err := json.Marshal(current)
+		if err != nil {
+			return fields, err
+		}
+		if doc, err = applyPatch(doc, patch); err != nil {
+			return fields, err
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(doc))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&fields); err != nil {
+			return fields, todoFieldsError("the patched todo is invalid: " + err.Error())
+		}
+
+		return fields, c.checkTodoFields(current, fields, user)
+	}
+}
+
+// todoFieldsError describes edited todo fields that are not valid.
+type todoFieldsError string
+
+func (e todoFieldsError) Error() string { return string(e) }
+
+// checkTodoFields validates the fields a todo is being edited to. A list the
+// todo is being moved into must belong to the user.
+func (c *TodoController) checkTodoFields(current, fields models.TodoFields, user *models.User) error {
+	if strings.TrimSpace(fields.Title) == "" {
+		return todoFieldsError("title is required")
+	}
+	if fields.Status == "" {
+		return todoFieldsError("status is required")
+	}
+	if utf8.RuneCountInString(fields.Description) > models.MaxDescriptionLength {
+		return todoFieldsError(fmt.Sprintf("description must be at most %d characters", models.MaxDescriptionLength))
+	}
+
+	if fields.ListID != nil && (current.ListID == nil || *current.ListID != *fields.ListID) {
+		list, err := c.ListStore.GetListByID(*fields.ListID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && list.UserID != user.ID) {
+			return todoFieldsError("invalid list ID")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeEditError responds with the error returned by TodoStore.EditTodo.
+// Invalid fields are reported with invalidStatus.
+func writeEditError(w http.ResponseWriter, err error, invalidStatus int) {
+	status, message := editErrorStatus(err, invalidStatus)
+	utils.HandleError(map[string]interface{}{
+		"error":   http.StatusText(status),
+		"message": message,
+	}, status, w)
+}
+
+// editErrorStatus maps an error from changing a todo to a response status and
+// message. Invalid fields are reported with invalidStatus.
+func editErrorStatus(err error, invalidStatus int) (int, string) {
+	var fieldsErr todoFieldsError
+	switch {
+	case errors.As(err, &fieldsErr):
+		return invalidStatus, fieldsErr.Error()
+	case errors.Is(err, models.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, err.Error()
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound, "Todo not found"
+	case errors.Is(err, models.ErrTodoBlocked), errors.Is(err, models.ErrWIPLimitReached), errors.Is(err, utils.ErrPatchTestFailed):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, utils.ErrMalformedPatch):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, utils.ErrInvalidPatch):
+		return http.StatusUnprocessableEntity, err.Error()
+	default:
+		return http.StatusInternalServerError, "Error updating todo"
+	}
 }
 
 func (c *TodoController) GetSingleTodo(w http.ResponseWriter, r *http.Request) {
@@ -176,11 +746,10 @@ func (c *TodoController) GetSingleTodo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Return the todo in the response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(todo)
+	writeTodo(r, w, c.TodoStore, todo)
 }
 
-// DeleteTodo deletes an existing todo for the authenticated user.
+// DeleteTodo moves an existing todo and its subtasks to the trash.
 func (c *TodoController) DeleteTodo(w http.ResponseWriter, r *http.Request) {
 	// Extract user ID from the request context that was set during authentication
 	user, ok := r.Context().Value("user").(*models.User)
@@ -205,9 +774,22 @@ func (c *TodoController) DeleteTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete the todo
-	err = c.TodoStore.DeleteTodo(todoID)
-	if err != nil {
+	ifVersions, ok := c.ifMatchVersions(r, w)
+	if !ok {
+		return
+	}
+
+	// Delete the todo
+	err = c.TodoStore.DeleteTodo(todoID, user.ID, ifVersions)
+	if errors.Is(err, models.ErrVersionMismatch) {
+		writePreconditionFailed(w)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Todo not found"}, http.StatusNotFound, w)
+		return
+	}
+	if err != nil {
 		utils.HandleError(map[string]interface{}{
 			"error":   "Data Error",
 			"message": "Error deleting todo",
@@ -218,3 +800,964 @@ func (c *TodoController) DeleteTodo(w http.ResponseWriter, r *http.Request) {
 	// Return success in the response
 	w.WriteHeader(http.StatusOK)
 }
+
+// GetWorkspaceTodos retrieves all todos in the workspace from the request URL.
+func (c *TodoController) GetWorkspaceTodos(w http.ResponseWriter, r *http.Request) {
+	// Parse workspace ID from the request URL
+	workspaceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid workspace ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	filter, err := parseTodoFilter(r)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Retrieve todos for the workspace
+	todos, err := c.TodoStore.GetTodosByWorkspaceID(workspaceID, filter)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "internal server error",
+			"message": "error retrieving todos",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	// Return todos in the response
+	writeTodoList(r, w, c.TodoStore, todos)
+}
+
+// CreateWorkspaceTodo creates a new todo in the workspace from the request URL.
+func (c *TodoController) CreateWorkspaceTodo(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse workspace ID from the request URL
+	workspaceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid workspace ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var newTodo models.Todo
+	err = json.NewDecoder(r.Body).Decode(&newTodo)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid request body",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	if !checkDescription(newTodo.Description, w) || !c.checkListOwner(newTodo.ListID, user, w) || !checkRecurrence(newTodo.Recurrence, w) {
+		return
+	}
+
+	// Create the todo
+	createdTodo, err := c.TodoStore.CreateTodo(models.Todo{
+		Title:       newTodo.Title,
+		Description: newTodo.Description,
+		Status:      models.TodoStatusActive,
+		UserID:      user.ID,
+		WorkspaceID: &workspaceID,
+		ListID:      newTodo.ListID,
+		DueAt:       newTodo.DueAt,
+		Recurrence:  newTodo.Recurrence,
+	})
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error creating todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	// Return the created todo in the response
+	writeTodo(r, w, c.TodoStore, createdTodo)
+}
+
+// GetListTodos retrieves the todos in the list from the request URL, using the
+// same filtering as GetTodosByUser.
+func (c *TodoController) GetListTodos(w http.ResponseWriter, r *http.Request) {
+	// Parse list ID from the request URL
+	listID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid list ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	filter, err := parseTodoFilter(r)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Retrieve todos for the list
+	todos, err := c.TodoStore.GetTodosByListID(listID, filter)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "internal server error",
+			"message": "error retrieving todos",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	// Return todos in the response
+	writeTodoList(r, w, c.TodoStore, todos)
+}
+
+// SetTodoList moves the todo in the request URL into another list, or out of
+// any list when list_id is null.
+func (c *TodoController) SetTodoList(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var body struct {
+		ListID *int `json:"list_id"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid request body",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	if !c.checkListOwner(body.ListID, user, w) {
+		return
+	}
+
+	todo, err := c.TodoStore.SetTodoList(todoID, body.ListID, user.ID)
+	if err != nil {
+		writeEditError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	writeTodo(r, w, c.TodoStore, todo)
+}
+
+// GetSubtasks retrieves the direct subtasks of the todo in the request URL.
+func (c *TodoController) GetSubtasks(w http.ResponseWriter, r *http.Request) {
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	todos, err := c.TodoStore.GetSubtasks(todoID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "internal server error",
+			"message": "error retrieving subtasks",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	writeTodoList(r, w, c.TodoStore, todos)
+}
+
+// CreateSubtask creates a new subtask under the todo in the request URL. The
+// subtask belongs to the same owner, workspace and list as its parent.
+func (c *TodoController) CreateSubtask(w http.ResponseWriter, r *http.Request) {
+	// Parse todo ID from the request URL
+	parentID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var newTodo models.Todo
+	err = json.NewDecoder(r.Body).Decode(&newTodo)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid request body",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	if !checkDescription(newTodo.Description, w) {
+		return
+	}
+
+	parent, err := c.TodoStore.GetTodoByID(parentID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Data Error",
+			"message": "Error retrieving todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	createdTodo, err := c.TodoStore.CreateTodo(models.Todo{
+		Title:       newTodo.Title,
+		Description: newTodo.Description,
+		Status:      models.TodoStatusActive,
+		UserID:      parent.UserID,
+		WorkspaceID: parent.WorkspaceID,
+		ListID:      parent.ListID,
+		ParentID:    &parent.ID,
+	})
+	if handleSubtaskError(err, w) {
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error creating todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	writeTodo(r, w, c.TodoStore, createdTodo)
+}
+
+// SetTodoParent makes the todo in the request URL a subtask of another todo
+// with the same owner and workspace, or a top-level todo when parent_id is null.
+func (c *TodoController) SetTodoParent(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var body struct {
+		ParentID *int `json:"parent_id"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid request body",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	if body.ParentID != nil {
+		todo, err := c.TodoStore.GetTodoByID(todoID)
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Todo not found"}, http.StatusNotFound, w)
+			return
+		}
+		if err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Data Error",
+				"message": "Error retrieving todo",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+
+		parent, err := c.TodoStore.GetTodoByID(*body.ParentID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Data Error",
+				"message": "Error retrieving todo",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+
+		// The caller must be able to read the parent, whose progress the subtask counts towards
+		access := models.TodoAccessNone
+		if err == nil {
+			if access, err = c.TodoStore.GetAccessLevel(parent, user.ID); err != nil {
+				utils.HandleError(map[string]interface{}{
+					"error":   "Internal Server Error",
+					"message": "Error checking todo access",
+				}, http.StatusInternalServerError, w)
+				return
+			}
+		}
+
+		if !models.TodoAccessAtLeast(access, models.TodoAccessRead) || parent.UserID != todo.UserID || !sameWorkspace(parent.WorkspaceID, todo.WorkspaceID) {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Bad Request",
+				"message": "The parent must be a todo with the same owner and workspace",
+			}, http.StatusBadRequest, w)
+			return
+		}
+	}
+
+	todo, err := c.TodoStore.SetTodoParent(todoID, body.ParentID, user.ID)
+	if handleSubtaskError(err, w) {
+		return
+	}
+	if err != nil {
+		writeEditError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	writeTodo(r, w, c.TodoStore, todo)
+}
+
+// handleSubtaskError writes a 400 response for subtask validation errors and
+// reports whether it did so.
+func handleSubtaskError(err error, w http.ResponseWriter) bool {
+	if errors.Is(err, models.ErrSubtaskCycle) || errors.Is(err, models.ErrSubtaskDepth) || errors.Is(err, models.ErrParentNotFound) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return true
+	}
+
+	return false
+}
+
+func sameWorkspace(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return *a == *b
+}
+
+// SetTodoRecurrence changes how the todo in the request URL repeats, and
+// optionally its due date. A null recurrence ends the series at this todo.
+func (c *TodoController) SetTodoRecurrence(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var body struct {
+		Recurrence *models.Recurrence `json:"recurrence"`
+		DueAt      *time.Time         `json:"due_at"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid request body",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	if !checkRecurrence(body.Recurrence, w) {
+		return
+	}
+
+	todo, err := c.TodoStore.SetRecurrence(todoID, body.Recurrence, body.DueAt, user.ID)
+	if err != nil {
+		writeEditError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	writeTodo(r, w, c.TodoStore, todo)
+}
+
+// GetTodoSeries retrieves every occurrence of the recurring series the todo in the request URL belongs to.
+func (c *TodoController) GetTodoSeries(w http.ResponseWriter, r *http.Request) {
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	todo, err := c.TodoStore.GetTodoByID(todoID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Data Error",
+			"message": "Error retrieving todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	todos := []models.Todo{*todo}
+	if todo.SeriesID != nil {
+		todos, err = c.TodoStore.GetSeries(*todo.SeriesID)
+		if err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "internal server error",
+				"message": "error retrieving todos",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+	}
+
+	writeTodoList(r, w, c.TodoStore, todos)
+}
+
+// checkRecurrence validates an optional recurrence rule, writing an error
+// response and returning false if it is invalid.
+func checkRecurrence(rec *models.Recurrence, w http.ResponseWriter) bool {
+	if rec == nil {
+		return true
+	}
+
+	if err := rec.Validate(); err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return false
+	}
+
+	return true
+}
+
+// AddDependency makes the todo in the request URL wait on the todo given by depends_on_id.
+func (c *TodoController) AddDependency(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var body struct {
+		DependsOnID int `json:"depends_on_id"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid request body",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// The user must at least be able to see the todo being waited on
+	blocker, err := c.TodoStore.GetTodoByID(body.DependsOnID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Data Error",
+			"message": "Error retrieving todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	access := models.TodoAccessNone
+	if err == nil {
+		if access, err = c.TodoStore.GetAccessLevel(blocker, user.ID); err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Internal Server Error",
+				"message": "Error checking todo access",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+	}
+
+	if !models.TodoAccessAtLeast(access, models.TodoAccessRead) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid depends_on_id",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	todo, err := c.TodoStore.AddDependency(todoID, body.DependsOnID)
+	if errors.Is(err, models.ErrDependencyCycle) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Conflict",
+			"message": err.Error(),
+		}, http.StatusConflict, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error adding dependency",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	writeTodo(r, w, c.TodoStore, todo)
+}
+
+// RemoveDependency stops the todo in the request URL from waiting on the todo given by dependsOnID.
+func (c *TodoController) RemoveDependency(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	dependsOnID, err := strconv.Atoi(vars["dependsOnID"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	todo, err := c.TodoStore.RemoveDependency(todoID, dependsOnID)
+	if err != nil {
+		writeEditError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	writeTodo(r, w, c.TodoStore, todo)
+}
+
+// GetNextActionable retrieves the authenticated user's open todos in the order
+// they can be worked on, with unblocked todos first.
+func (c *TodoController) GetNextActionable(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	todos, err := c.TodoStore.GetNextActionable(user.ID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "internal server error",
+			"message": "error retrieving todos",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	writeTodoList(r, w, c.TodoStore, todos)
+}
+
+// MoveTodo places the todo in the request URL directly before or after another
+// todo, given by before_id or after_id.
+func (c *TodoController) MoveTodo(w http.ResponseWriter, r *http.Request) {
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var body struct {
+		BeforeID *int `json:"before_id"`
+		AfterID  *int `json:"after_id"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || (body.BeforeID == nil) == (body.AfterID == nil) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Exactly one of before_id and after_id is required",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	referenceID, after := 0, body.AfterID != nil
+	if after {
+		referenceID = *body.AfterID
+	} else {
+		referenceID = *body.BeforeID
+	}
+
+	todo, err := c.TodoStore.MoveTodo(todoID, referenceID, after)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Todo not found"}, http.StatusNotFound, w)
+		return
+	}
+	if errors.Is(err, models.ErrInvalidMove) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error moving todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	writeTodo(r, w, c.TodoStore, todo)
+}
+
+// SearchTodos finds the todos readable by the authenticated user whose title or
+// comments match the q query parameter, best matches first.
+func (c *TodoController) SearchTodos(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	limit, offset, err := parsePage(r, maxTodoPageSize)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+	if limit == 0 {
+		limit = defaultSearchPageSize
+	}
+
+	results, err := c.TodoStore.SearchTodos(user.ID, r.URL.Query().Get("q"), limit, offset)
+	if errors.Is(err, models.ErrEmptySearch) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "The q parameter must contain at least one word",
+		}, http.StatusBadRequest, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error searching todos",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	todos := make([]models.Todo, len(results))
+	for i := range results {
+		todos[i] = results[i].Todo
+	}
+	if err := hideDependencies(r, c.TodoStore, todos); err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error searching todos",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+	for i := range results {
+		results[i].Todo = todos[i]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// GetTrash retrieves a page of the todos in the trash that the authenticated
+// user can read, most recently deleted first.
+func (c *TodoController) GetTrash(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	limit, offset, err := parsePage(r, maxTodoPageSize)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+	if limit == 0 {
+		limit = maxTodoPageSize
+	}
+
+	todos, err := c.TodoStore.GetTrash(user.ID, limit, offset)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving trash",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	writeTodoList(r, w, c.TodoStore, todos)
+}
+
+// RestoreTodo takes the todo in the request URL out of the trash, together
+// with the subtasks deleted with it.
+func (c *TodoController) RestoreTodo(w http.ResponseWriter, r *http.Request) {
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	todo, err := c.TodoStore.RestoreTodo(todoID)
+	if errors.Is(err, models.ErrParentInTrash) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Conflict",
+			"message": err.Error(),
+		}, http.StatusConflict, w)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Todo not found in the trash"}, http.StatusNotFound, w)
+		return
+	}
+	if err != nil {
+		writeEditError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	writeTodo(r, w, c.TodoStore, todo)
+}
+
+// PurgeTodo permanently deletes the todo in the request URL, which must be in
+// the trash, together with its subtasks.
+func (c *TodoController) PurgeTodo(w http.ResponseWriter, r *http.Request) {
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	err = c.TodoStore.PurgeTodo(todoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Todo not found in the trash"}, http.StatusNotFound, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Data Error",
+			"message": "Error deleting todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetTodoHistory retrieves a page of the revisions of the todo in the request
+// URL, newest first, with the fields each one changed and who changed them.
+func (c *TodoController) GetTodoHistory(w http.ResponseWriter, r *http.Request) {
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	limit, offset, err := parsePage(r, maxTodoPageSize)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": err.Error(),
+		}, http.StatusBadRequest, w)
+		return
+	}
+	if limit == 0 {
+		limit = defaultHistoryPageSize
+	}
+
+	revisions, err := c.TodoStore.GetTodoHistory(todoID, limit, offset)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving todo history",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// GetTodoRevision retrieves the revision in the request URL, showing the todo
+// as it was at that point in its history.
+func (c *TodoController) GetTodoRevision(w http.ResponseWriter, r *http.Request) {
+	todoID, revision, ok := parseRevisionPath(r, w)
+	if !ok {
+		return
+	}
+
+	todoRevision, err := c.TodoStore.GetTodoRevision(todoID, revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Revision not found"}, http.StatusNotFound, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving revision",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(todoRevision)
+}
+
+// RevertTodo restores the todo in the request URL to how it was at the
+// revision in the request URL, recording the revert as a new revision.
+func (c *TodoController) RevertTodo(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	todoID, revision, ok := parseRevisionPath(r, w)
+	if !ok {
+		return
+	}
+
+	todo, err := c.TodoStore.RevertTodo(todoID, revision, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Revision not found"}, http.StatusNotFound, w)
+		return
+	}
+	if errors.Is(err, models.ErrTodoBlocked) || errors.Is(err, models.ErrWIPLimitReached) ||
+		errors.Is(err, models.ErrParentNotFound) || errors.Is(err, models.ErrSubtaskCycle) || errors.Is(err, models.ErrSubtaskDepth) ||
+		errors.Is(err, models.ErrListNotOwned) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Conflict",
+			"message": err.Error(),
+		}, http.StatusConflict, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error reverting todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	writeTodo(r, w, c.TodoStore, todo)
+}
+
+// parseRevisionPath parses the todo ID and revision number from the request
+// URL, writing an error response and returning false if either is invalid.
+func parseRevisionPath(r *http.Request, w http.ResponseWriter) (int, int, bool) {
+	vars := mux.Vars(r)
+
+	todoID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return 0, 0, false
+	}
+
+	revision, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid revision",
+		}, http.StatusBadRequest, w)
+		return 0, 0, false
+	}
+
+	return todoID, revision, true
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+// TodoShareController handles sharing individual todos with other users.
+type TodoShareController struct {
+	TodoShareStore models.TodoShareStore
+	TodoStore      models.TodoStore
+	UserStore      models.UserStore
+}
+
+// NewTodoShareController creates a new TodoShareController instance.
+func NewTodoShareController(todoShareStore models.TodoShareStore, todoStore models.TodoStore, userStore models.UserStore) *TodoShareController {
+	return &TodoShareController{TodoShareStore: todoShareStore, TodoStore: todoStore, UserStore: userStore}
+}
+
+// ShareTodo shares the todo in the request URL with a user at read or edit level.
+func (c *TodoShareController) ShareTodo(w http.ResponseWriter, r *http.Request) {
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var body struct {
+		Username string `json:"username"`
+		Level    string `json:"level"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.Username == "" || !models.IsValidShareLevel(body.Level) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "A username and a level of read or edit are required",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	recipient, err := c.UserStore.GetUserByUsername(body.Username)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "User not found"}, http.StatusNotFound, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving user",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	todo, err := c.TodoStore.GetTodoByID(todoID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Data Error",
+			"message": "Error retrieving todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	if recipient.ID == todo.UserID {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "A todo cannot be shared with its owner",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	err = c.TodoShareStore.ShareTodo(todoID, recipient.ID, body.Level)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error sharing todo",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TodoShare{
+		TodoID:   todoID,
+		UserID:   recipient.ID,
+		Username: recipient.Username,
+		Level:    body.Level,
+	})
+}
+
+// GetShares lists the users the todo in the request URL is shared with.
+func (c *TodoShareController) GetShares(w http.ResponseWriter, r *http.Request) {
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	shares, err := c.TodoShareStore.GetSharesByTodoID(todoID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving shares",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shares)
+}
+
+// RevokeShare removes the share of the todo in the request URL with the user in the request URL.
+func (c *TodoShareController) RevokeShare(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// Parse todo ID from the request URL
+	todoID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid todo ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	userID, err := strconv.Atoi(vars["userID"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid user ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	err = c.TodoShareStore.RevokeShare(todoID, userID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error revoking share",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetSharedTodos retrieves the todos other users have shared with the authenticated user.
+func (c *TodoShareController) GetSharedTodos(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	todos, err := c.TodoShareStore.GetTodosSharedWithUser(user.ID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "internal server error",
+			"message": "error retrieving shared todos",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	plainTodos := make([]models.Todo, len(todos))
+	for i := range todos {
+		plainTodos[i] = todos[i].Todo
+	}
+	if err := hideDependencies(r, c.TodoStore, plainTodos); err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "internal server error",
+			"message": "error retrieving shared todos",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+	for i := range todos {
+		todos[i].Todo = plainTodos[i]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(todos)
+}
@@ -12,12 +12,13 @@ import (
 // UserController handles user-related HTTP requests.
 type UserController struct {
 	UserStore      models.UserStore
+	AuditStore     models.AuditStore
 	authMiddleware middlewares.AuthMiddleware
 }
 
 // NewUserController creates a new UserController instance.
-func NewUserController(userStore models.UserStore) *UserController {
-	return &UserController{UserStore: userStore}
+func NewUserController(userStore models.UserStore, auditStore models.AuditStore) *UserController {
+	return &UserController{UserStore: userStore, AuditStore: auditStore}
 }
 
 // RegisterUser handles user registration.
@@ -39,10 +40,23 @@ func (c *UserController) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	// Create the user (including password hashing)
 	createdUser, err := c.UserStore.CreateUser(newUser.Username, newUser.Password, "user")
 	if err != nil {
+		middlewares.RecordAudit(c.AuditStore, r, models.AuditEvent{
+			Event:     models.AuditUserRegister,
+			Outcome:   models.AuditFailure,
+			ActorName: newUser.Username,
+			Details:   map[string]interface{}{"reason": "error creating user"},
+		})
 		http.Error(w, "Error creating user", http.StatusInternalServerError)
 		return
 	}
 
+	middlewares.RecordAudit(c.AuditStore, r, models.AuditEvent{
+		Event:     models.AuditUserRegister,
+		Outcome:   models.AuditSuccess,
+		ActorID:   &createdUser.ID,
+		ActorName: createdUser.Username,
+	})
+
 	// Omit the Password field from the response
 	createdUser.Password = ""
 
@@ -70,6 +84,12 @@ func (c *UserController) LoginUser(w http.ResponseWriter, r *http.Request) {
 	// Verify user credentials
 	user, err := c.UserStore.VerifyUserCredentials(loginUser.Username, loginUser.Password)
 	if err != nil {
+		middlewares.RecordAudit(c.AuditStore, r, models.AuditEvent{
+			Event:     models.AuditUserLogin,
+			Outcome:   models.AuditFailure,
+			ActorName: loginUser.Username,
+			Details:   map[string]interface{}{"reason": "invalid username or password"},
+		})
 		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 		return
 	}
@@ -81,6 +101,13 @@ func (c *UserController) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	middlewares.RecordAudit(c.AuditStore, r, models.AuditEvent{
+		Event:     models.AuditUserLogin,
+		Outcome:   models.AuditSuccess,
+		ActorID:   &user.ID,
+		ActorName: user.Username,
+	})
+
 	// Omit the Password field from the response
 	user.Password = ""
 
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+// WorkspaceController handles workspace-related HTTP requests.
+type WorkspaceController struct {
+	WorkspaceStore models.WorkspaceStore
+	UserStore      models.UserStore
+}
+
+// NewWorkspaceController creates a new WorkspaceController instance.
+func NewWorkspaceController(workspaceStore models.WorkspaceStore, userStore models.UserStore) *WorkspaceController {
+	return &WorkspaceController{WorkspaceStore: workspaceStore, UserStore: userStore}
+}
+
+// CreateWorkspace creates a new workspace owned by the authenticated user.
+func (c *WorkspaceController) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var newWorkspace models.Workspace
+	err := json.NewDecoder(r.Body).Decode(&newWorkspace)
+	if err != nil || strings.TrimSpace(newWorkspace.Name) == "" {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid request body",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Create the workspace
+	createdWorkspace, err := c.WorkspaceStore.CreateWorkspace(newWorkspace.Name, user.ID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error creating workspace",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	// Return the created workspace in the response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(createdWorkspace)
+}
+
+// GetWorkspacesByUser retrieves the workspaces the authenticated user is a member of.
+func (c *WorkspaceController) GetWorkspacesByUser(w http.ResponseWriter, r *http.Request) {
+	// Extract user ID from the request context that was set during authentication
+	user, ok := r.Context().Value("user").(*models.User)
+	if !ok || user == nil {
+		utils.HandleError(map[string]interface{}{
+			"error": "Unauthorized",
+		}, http.StatusUnauthorized, w)
+		return
+	}
+
+	workspaces, err := c.WorkspaceStore.GetWorkspacesByUserID(user.ID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving workspaces",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workspaces)
+}
+
+// GetMembers lists the members of the workspace from the request URL.
+func (c *WorkspaceController) GetMembers(w http.ResponseWriter, r *http.Request) {
+	workspaceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid workspace ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	members, err := c.WorkspaceStore.GetMembers(workspaceID)
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving workspace members",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// SetMember adds a user, identified by username, to the workspace or changes their role.
+func (c *WorkspaceController) SetMember(w http.ResponseWriter, r *http.Request) {
+	workspaceID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid workspace ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	// Parse the JSON request body
+	var body struct {
+		Username string `json:"username"`
+		Role     string `json:"role"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.Username == "" || !models.IsValidWorkspaceRole(body.Role) {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "A username and a role of owner, editor or viewer are required",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	member, err := c.UserStore.GetUserByUsername(body.Username)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "User not found"}, http.StatusNotFound, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error retrieving user",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	err = c.WorkspaceStore.SetMember(workspaceID, member.ID, body.Role)
+	if errors.Is(err, models.ErrLastWorkspaceOwner) {
+		utils.HandleError(map[string]interface{}{"error": "Conflict", "message": err.Error()}, http.StatusConflict, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error updating workspace member",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.WorkspaceMember{UserID: member.ID, Username: member.Username, Role: body.Role})
+}
+
+// RemoveMember removes the user in the request URL from the workspace.
+func (c *WorkspaceController) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	workspaceID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid workspace ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	userID, err := strconv.Atoi(vars["userID"])
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Bad Request",
+			"message": "Invalid user ID",
+		}, http.StatusBadRequest, w)
+		return
+	}
+
+	err = c.WorkspaceStore.RemoveMember(workspaceID, userID)
+	if errors.Is(err, models.ErrLastWorkspaceOwner) {
+		utils.HandleError(map[string]interface{}{"error": "Conflict", "message": err.Error()}, http.StatusConflict, w)
+		return
+	}
+	if err != nil {
+		utils.HandleError(map[string]interface{}{
+			"error":   "Internal Server Error",
+			"message": "Error removing workspace member",
+		}, http.StatusInternalServerError, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,71 @@
+// Package blobstore stores file contents, such as todo attachments, outside the database.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrNotFound is returned when a blob does not exist.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore saves and retrieves blobs by key. Keys are slash separated paths
+// made of letters, digits, dashes, underscores and dots.
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any existing blob.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the blob stored under key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// FromEnv creates the BlobStore selected by the ATTACHMENT_STORAGE environment
+// variable: "local" (the default) stores blobs under ATTACHMENT_DIR, and "s3"
+// stores them in an S3-compatible bucket configured by the S3_* variables.
+func FromEnv() (BlobStore, error) {
+	switch storage := os.Getenv("ATTACHMENT_STORAGE"); storage {
+	case "", "local":
+		dir := os.Getenv("ATTACHMENT_DIR")
+		if dir == "" {
+			dir = "attachments"
+		}
+		return NewLocalStore(dir)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown ATTACHMENT_STORAGE %q", storage)
+	}
+}
+
+// validKey reports whether key can be used as a blob key.
+func validKey(key string) bool {
+	if key == "" || key[0] == '/' || key[len(key)-1] == '/' {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '/':
+		case c == '.':
+			// Reject "." and ".." path segments
+			if (i == 0 || key[i-1] == '/') && (i+1 == len(key) || key[i+1] == '/' || key[i+1] == '.') {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,356 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+)
+
+// migrations holds the schema changes applied by Migrate, in order. The index
+// of an entry (starting at 1) is its version, so released entries must never be
+// edited or reordered; append a new one instead.
+var migrations = []string{
+	// 1: base schema
+	`CREATE TABLE IF NOT EXISTS users (
+		id SERIAL PRIMARY KEY,
+		username TEXT NOT NULL UNIQUE,
+		password TEXT NOT NULL,
+		role TEXT NOT NULL DEFAULT 'user'
+	);
+	CREATE TABLE IF NOT EXISTS todos (
+		id SERIAL PRIMARY KEY,
+		title TEXT NOT NULL,
+		status TEXT NOT NULL,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE
+	);`,
+
+	// 2: role -> permission mapping
+	`CREATE TABLE role_permissions (
+		role TEXT NOT NULL,
+		permission TEXT NOT NULL,
+		PRIMARY KEY (role, permission)
+	);
+	INSERT INTO role_permissions(role, permission) VALUES
+		('user', 'todo:read'),
+		('user', 'todo:write'),
+		('user', 'todo:delete'),
+		('admin', 'todo:read'),
+		('admin', 'todo:write'),
+		('admin', 'todo:delete'),
+		('admin', 'todo:manage'),
+		('admin', 'user:admin');`,
+
+	// 3: workspaces with membership
+	`CREATE TABLE workspaces (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE TABLE workspace_members (
+		workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		role TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+		PRIMARY KEY (workspace_id, user_id)
+	);
+	CREATE INDEX workspace_members_user_id_idx ON workspace_members(user_id);
+	ALTER TABLE todos ADD COLUMN workspace_id INTEGER REFERENCES workspaces(id) ON DELETE CASCADE;
+	CREATE INDEX todos_workspace_id_idx ON todos(workspace_id);`,
+
+	// 4: individual todo shares
+	`CREATE TABLE todo_shares (
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		level TEXT NOT NULL CHECK (level IN ('read', 'edit')),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (todo_id, user_id)
+	);
+	CREATE INDEX todo_shares_user_id_idx ON todo_shares(user_id);`,
+
+	// 5: todo lists
+	`CREATE TABLE lists (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		color TEXT NOT NULL DEFAULT '',
+		archived BOOLEAN NOT NULL DEFAULT false,
+		position INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE INDEX lists_user_id_idx ON lists(user_id);
+	ALTER TABLE todos ADD COLUMN list_id INTEGER REFERENCES lists(id) ON DELETE SET NULL;
+	CREATE INDEX todos_list_id_idx ON todos(list_id);`,
+
+	// 6: tags
+	`CREATE TABLE tags (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		color TEXT NOT NULL DEFAULT '',
+		UNIQUE (user_id, name)
+	);
+	CREATE TABLE todo_tags (
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+		PRIMARY KEY (todo_id, tag_id)
+	);
+	CREATE INDEX todo_tags_tag_id_idx ON todo_tags(tag_id);`,
+
+	// 7: subtasks
+	`ALTER TABLE todos ADD COLUMN parent_id INTEGER REFERENCES todos(id);
+	CREATE INDEX todos_parent_id_idx ON todos(parent_id);`,
+
+	// 8: due dates and recurring todos
+	`ALTER TABLE todos ADD COLUMN due_at TIMESTAMPTZ;
+	ALTER TABLE todos ADD COLUMN recurrence JSONB;
+	ALTER TABLE todos ADD COLUMN series_id INTEGER REFERENCES todos(id) ON DELETE SET NULL;
+	ALTER TABLE todos ADD COLUMN occurrence INTEGER;
+	CREATE INDEX todos_series_id_idx ON todos(series_id);`,
+
+	// 9: todo dependencies
+	`CREATE TABLE todo_dependencies (
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		depends_on_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		PRIMARY KEY (todo_id, depends_on_id),
+		CHECK (todo_id <> depends_on_id)
+	);
+	CREATE INDEX todo_dependencies_depends_on_id_idx ON todo_dependencies(depends_on_id);`,
+
+	// 10: manual ordering
+	`CREATE SEQUENCE todos_position_seq;
+	ALTER TABLE todos ADD COLUMN position DOUBLE PRECISION;
+	UPDATE todos SET position = id * 1024;
+	SELECT setval('todos_position_seq', COALESCE(MAX(id), 0) + 1) FROM todos;
+	ALTER TABLE todos ALTER COLUMN position SET DEFAULT nextval('todos_position_seq') * 1024;
+	ALTER TABLE todos ALTER COLUMN position SET NOT NULL;
+	CREATE INDEX todos_user_id_position_idx ON todos(user_id, position);
+	CREATE INDEX todos_workspace_id_position_idx ON todos(workspace_id, position);`,
+
+	// 11: kanban boards
+	`CREATE TABLE boards (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		list_id INTEGER REFERENCES lists(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE INDEX boards_user_id_idx ON boards(user_id);
+	CREATE INDEX boards_list_id_idx ON boards(list_id);
+	CREATE TABLE board_columns (
+		id SERIAL PRIMARY KEY,
+		board_id INTEGER NOT NULL REFERENCES boards(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		status TEXT NOT NULL,
+		wip_limit INTEGER CHECK (wip_limit >= 0),
+		position INTEGER NOT NULL,
+		UNIQUE (board_id, status)
+	);`,
+
+	// 12: todo comments
+	`CREATE TABLE todo_comments (
+		id SERIAL PRIMARY KEY,
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		body TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		edited_at TIMESTAMPTZ,
+		deleted_at TIMESTAMPTZ
+	);
+	CREATE INDEX todo_comments_todo_id_idx ON todo_comments(todo_id, created_at);`,
+
+	// 13: todo attachments, with a queue of stored blobs left behind by deleted attachments
+	`CREATE TABLE todo_attachments (
+		id SERIAL PRIMARY KEY,
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		filename TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size BIGINT NOT NULL,
+		storage_key TEXT NOT NULL UNIQUE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE INDEX todo_attachments_todo_id_idx ON todo_attachments(todo_id);
+	CREATE TABLE attachment_blob_deletions (
+		storage_key TEXT PRIMARY KEY,
+		queued_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE FUNCTION queue_attachment_blob_deletion() RETURNS trigger AS $$
+	BEGIN
+		INSERT INTO attachment_blob_deletions(storage_key) VALUES (OLD.storage_key) ON CONFLICT DO NOTHING;
+		RETURN OLD;
+	END;
+	$$ LANGUAGE plpgsql;
+	CREATE TRIGGER todo_attachments_queue_blob_deletion AFTER DELETE ON todo_attachments
+		FOR EACH ROW EXECUTE PROCEDURE queue_attachment_blob_deletion();`,
+
+	// 14: full-text search over todos and their comments
+	`ALTER TABLE todos ADD COLUMN search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('english', title)) STORED;
+	CREATE INDEX todos_search_vector_idx ON todos USING GIN (search_vector);
+	ALTER TABLE todo_comments ADD COLUMN search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('english', body)) STORED;
+	CREATE INDEX todo_comments_search_vector_idx ON todo_comments USING GIN (search_vector);`,
+
+	// 15: todo descriptions, searched with a lower weight than titles
+	`ALTER TABLE todos ADD COLUMN description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE todos DROP COLUMN search_vector;
+	ALTER TABLE todos ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', description), 'B')
+	) STORED;
+	CREATE INDEX todos_search_vector_idx ON todos USING GIN (search_vector);`,
+
+	// 16: soft deletion of todos
+	`ALTER TABLE todos ADD COLUMN deleted_at TIMESTAMPTZ;
+	ALTER TABLE todos ADD COLUMN deleted_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+	CREATE INDEX todos_deleted_at_idx ON todos(deleted_at) WHERE deleted_at IS NOT NULL;`,
+
+	// 17: append-only revision history of todos
+	`CREATE TABLE todo_revisions (
+		id SERIAL PRIMARY KEY,
+		todo_id INTEGER NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+		revision INTEGER NOT NULL,
+		user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		snapshot JSONB NOT NULL,
+		changes JSONB NOT NULL,
+		reverted_from INTEGER,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		UNIQUE (todo_id, revision)
+	);
+	CREATE FUNCTION reject_todo_revision_update() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'todo revisions are append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+	CREATE TRIGGER todo_revisions_append_only BEFORE UPDATE ON todo_revisions
+		FOR EACH ROW EXECUTE PROCEDURE reject_todo_revision_update();`,
+
+	// 18: security audit log
+	`CREATE TABLE audit_events (
+		id BIGSERIAL PRIMARY KEY,
+		event TEXT NOT NULL,
+		outcome TEXT NOT NULL,
+		actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+		actor_name TEXT NOT NULL DEFAULT '',
+		target TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		details JSONB NOT NULL DEFAULT '{}',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	CREATE INDEX audit_events_created_at_idx ON audit_events(created_at);
+	CREATE INDEX audit_events_event_idx ON audit_events(event, created_at);
+	CREATE INDEX audit_events_actor_id_idx ON audit_events(actor_id, created_at);`,
+
+	// 19: todo versions for optimistic concurrency, bumped by every update
+	`ALTER TABLE todos ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+	CREATE FUNCTION bump_todo_version() RETURNS trigger AS $$
+	BEGIN
+		NEW.version := OLD.version + 1;
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;
+	CREATE TRIGGER todos_bump_version BEFORE UPDATE ON todos
+		FOR EACH ROW EXECUTE PROCEDURE bump_todo_version();`,
+
+	// 20: idempotency keys with the responses they produced
+	`CREATE TABLE idempotency_keys (
+		id BIGSERIAL PRIMARY KEY,
+		user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+		key TEXT NOT NULL,
+		fingerprint TEXT NOT NULL,
+		status INTEGER,
+		content_type TEXT NOT NULL DEFAULT '',
+		body BYTEA,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		expires_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE UNIQUE INDEX idempotency_keys_user_key_idx ON idempotency_keys(COALESCE(user_id, 0), key);
+	CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys(expires_at);`,
+
+	// 21: token buckets of the rate limiter
+	`CREATE TABLE rate_limit_buckets (
+		key TEXT PRIMARY KEY,
+		tokens DOUBLE PRECISION NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		full_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX rate_limit_buckets_full_at_idx ON rate_limit_buckets(full_at);`,
+
+	// 22: reordering todos does not bump their versions, so it does not invalidate ETags held by clients
+	`CREATE OR REPLACE FUNCTION bump_todo_version() RETURNS trigger AS $$
+	BEGIN
+		IF to_jsonb(NEW) - 'position' - 'version' IS DISTINCT FROM to_jsonb(OLD) - 'position' - 'version' THEN
+			NEW.version := OLD.version + 1;
+		ELSE
+			NEW.version := OLD.version;
+		END IF;
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;`,
+
+	// 23: compare the versioned columns by name, since the generated search_vector
+	// is not computed yet in BEFORE triggers and other new columns are not versioned
+	`CREATE OR REPLACE FUNCTION bump_todo_version() RETURNS trigger AS $$
+	BEGIN
+		IF (NEW.title, NEW.description, NEW.status, NEW.user_id, NEW.workspace_id, NEW.list_id, NEW.parent_id,
+			NEW.due_at, NEW.recurrence, NEW.series_id, NEW.occurrence, NEW.deleted_at, NEW.deleted_by)
+			IS DISTINCT FROM
+			(OLD.title, OLD.description, OLD.status, OLD.user_id, OLD.workspace_id, OLD.list_id, OLD.parent_id,
+			OLD.due_at, OLD.recurrence, OLD.series_id, OLD.occurrence, OLD.deleted_at, OLD.deleted_by) THEN
+			NEW.version := OLD.version + 1;
+		ELSE
+			NEW.version := OLD.version;
+		END IF;
+		RETURN NEW;
+	END;
+	$$ LANGUAGE plpgsql;`,
+
+	// 24: idempotency keys of unauthenticated requests scoped by client IP, and the replayed headers
+	`ALTER TABLE idempotency_keys ADD COLUMN client_ip TEXT NOT NULL DEFAULT '';
+	ALTER TABLE idempotency_keys ADD COLUMN headers JSONB NOT NULL DEFAULT '{}';
+	DROP INDEX idempotency_keys_user_key_idx;
+	CREATE UNIQUE INDEX idempotency_keys_scope_key_idx ON idempotency_keys(COALESCE(user_id, 0), client_ip, key);`,
+}
+
+// Migrate applies every migration that has not yet been recorded in the
+// schema_migrations table. Each migration runs in its own transaction.
+func Migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if _, err := tx.Exec("INSERT INTO schema_migrations(version) VALUES($1)", version); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		log.Printf("Applied database migration %d", version)
+	}
+
+	return nil
+}
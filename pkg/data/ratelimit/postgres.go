@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// PostgresStore keeps token buckets in the rate_limit_buckets table, so that
+// limits are shared by every server using the database.
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+// NewPostgresStore creates a PostgresStore using db.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+// Take takes a token from the bucket of key. The bucket row is locked while it
+// is updated and the database clock is used, so concurrent requests to
+// different servers are counted correctly.
+func (s *PostgresStore) Take(ctx context.Context, key string, policy Policy) (Result, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback()
+
+	// Create the bucket full if it does not exist yet
+	query := `INSERT INTO rate_limit_buckets(key, tokens, updated_at, full_at)
+		VALUES($1, $2, now(), now()) ON CONFLICT (key) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, key, policy.Limit); err != nil {
+		return Result{}, err
+	}
+
+	var tokens float64
+	var updatedAt, now time.Time
+	query = "SELECT tokens, updated_at, now() FROM rate_limit_buckets WHERE key = $1 FOR UPDATE"
+	if err := tx.QueryRowContext(ctx, query, key).Scan(&tokens, &updatedAt, &now); err != nil {
+		return Result{}, err
+	}
+
+	tokens, result := take(tokens, now.Sub(updatedAt), policy)
+
+	query = "UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3, full_at = $4 WHERE key = $1"
+	if _, err := tx.ExecContext(ctx, query, key, tokens, now, now.Add(result.Reset)); err != nil {
+		return Result{}, err
+	}
+
+	return result, tx.Commit()
+}
+
+// Purge deletes the buckets that are full again, which behave the same as
+// missing ones, and returns how many were deleted.
+func (s *PostgresStore) Purge(ctx context.Context) (int64, error) {
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE full_at <= now()")
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
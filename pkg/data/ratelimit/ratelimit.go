@@ -0,0 +1,138 @@
+// Package ratelimit limits how often clients may make requests, using token buckets.
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy allows Limit requests per Window. Unused requests accumulate up to
+// Limit, so a client may burst up to Limit requests after being idle. A
+// policy with a Limit of zero allows every request.
+type Policy struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Disabled reports whether the policy allows every request.
+func (p Policy) Disabled() bool {
+	return p.Limit <= 0 || p.Window <= 0
+}
+
+// String formats the policy like ParsePolicy accepts it.
+func (p Policy) String() string {
+	if p.Disabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", p.Limit, p.Window)
+}
+
+// rate returns the number of tokens added to a bucket per second.
+func (p Policy) rate() float64 {
+	return float64(p.Limit) / p.Window.Seconds()
+}
+
+// ParsePolicy parses a policy written as a request count and a Go duration,
+// such as "100/1m", or "off" for a policy that allows every request.
+func ParsePolicy(s string) (Policy, error) {
+	if s == "off" || s == "0" {
+		return Policy{}, nil
+	}
+
+	count, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("invalid rate limit %q: expected requests/duration", s)
+	}
+	limit, err := strconv.Atoi(count)
+	if err != nil || limit < 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit %q: bad request count", s)
+	}
+	duration, err := time.ParseDuration(window)
+	if err != nil || duration <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit %q: bad duration", s)
+	}
+
+	return Policy{Limit: limit, Window: duration}, nil
+}
+
+// PolicyFromEnv parses the policy in the environment variable name, returning
+// def if it is not set.
+func PolicyFromEnv(name string, def Policy) (Policy, error) {
+	s := os.Getenv(name)
+	if s == "" {
+		return def, nil
+	}
+
+	policy, err := ParsePolicy(s)
+	if err != nil {
+		return Policy{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return policy, nil
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	// Allowed reports whether the request may proceed.
+	Allowed bool
+	// Limit is the size of the bucket.
+	Limit int
+	// Remaining is the number of requests still allowed right away.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, if this one was not.
+	RetryAfter time.Duration
+}
+
+// Store keeps a token bucket for every key.
+type Store interface {
+	// Take refills the bucket of key for the time passed since it was last
+	// used and takes a token from it if there is one.
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// FromEnv creates the Store selected by the RATE_LIMIT_STORE environment
+// variable: "memory" (the default) keeps buckets in this process, and
+// "postgres" keeps them in db so that they are shared by every instance of
+// the server.
+func FromEnv(db *sql.DB) (Store, error) {
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "postgres":
+		return NewPostgresStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_STORE %q", store)
+	}
+}
+
+// take refills a bucket holding tokens that was last used elapsed ago and
+// takes a token from it, returning the tokens left and the result.
+func take(tokens float64, elapsed time.Duration, policy Policy) (float64, Result) {
+	rate := policy.rate()
+	if elapsed > 0 {
+		tokens = math.Min(float64(policy.Limit), tokens+elapsed.Seconds()*rate)
+	}
+
+	result := Result{Limit: policy.Limit}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	result.Remaining = int(tokens)
+	result.Reset = seconds((float64(policy.Limit) - tokens) / rate)
+
+	return tokens, result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+// RecordAudit appends an event to the audit log, adding the client address and
+// user agent of the request and, unless the event names one, the authenticated
+// user as the actor. Failing to record is logged rather than failing the request.
+func RecordAudit(auditStore models.AuditStore, r *http.Request, event models.AuditEvent) {
+	event.IP = utils.ClientIP(r)
+	event.UserAgent = r.UserAgent()
+	if user, ok := r.Context().Value("user").(*models.User); ok && user != nil && event.ActorID == nil {
+		event.ActorID = &user.ID
+		event.ActorName = user.Username
+	}
+
+	if err := auditStore.RecordEvent(event); err != nil {
+		log.Printf("Error recording audit event %s: %v", event.Event, err)
+	}
+}
@@ -18,12 +18,13 @@ import (
 
 // AuthMiddleware handles user authentication.
 type AuthMiddleware struct {
-	UserStore models.UserStore
+	UserStore  models.UserStore
+	AuditStore models.AuditStore
 }
 
 // NewAuthMiddleware creates a new AuthMiddleware instance.
-func NewAuthMiddleware(userStore models.UserStore) *AuthMiddleware {
-	return &AuthMiddleware{UserStore: userStore}
+func NewAuthMiddleware(userStore models.UserStore, auditStore models.AuditStore) *AuthMiddleware {
+	return &AuthMiddleware{UserStore: userStore, AuditStore: auditStore}
 }
 
 // Authenticate is the middleware function that performs user authentication.
@@ -35,6 +36,21 @@ func (m *AuthMiddleware) Authenticate(next http.HandlerFunc) http.HandlerFunc {
 		// Verify the token against the user store
 		user, err := m.verifyJWTToken(token)
 		if err != nil {
+			// Requests without a token are routine, only rejected tokens are audited
+			if token != "" {
+				reason := "invalid token"
+				var validationErr *jwt.ValidationError
+				if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+					reason = "expired token"
+				}
+				RecordAudit(m.AuditStore, r, models.AuditEvent{
+					Event:   models.AuditAuthFailure,
+					Outcome: models.AuditFailure,
+					Target:  r.Method + " " + r.URL.Path,
+					Details: map[string]interface{}{"reason": reason},
+				})
+			}
+
 			jsonResponse := map[string]interface{}{
 				"error": "Unauthorized",
 			}
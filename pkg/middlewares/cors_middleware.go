@@ -0,0 +1,125 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSMiddleware lets browser applications served from other origins call the
+// API by answering CORS preflight requests and adding the CORS response headers.
+type CORSMiddleware struct {
+	// AllowedOrigins lists the origins allowed to call the API, such as
+	// "https://app.example.com". "*" allows every origin.
+	AllowedOrigins []string
+	// AllowedMethods lists the methods cross-origin requests may use.
+	AllowedMethods []string
+	// AllowedHeaders lists the request headers cross-origin requests may send.
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers cross-origin callers may read.
+	ExposedHeaders []string
+	// AllowCredentials lets cross-origin requests send cookies and HTTP authentication.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache the answer to a preflight request.
+	MaxAge time.Duration
+}
+
+// NewCORSMiddleware creates a new CORSMiddleware instance allowing allowedOrigins
+// to use the methods and headers of the API.
+func NewCORSMiddleware(allowedOrigins []string) *CORSMiddleware {
+	return &CORSMiddleware{
+		AllowedOrigins: allowedOrigins,
+		AllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders: []string{"Authorization", "Content-Type", "If-Match", "If-None-Match", "Idempotency-Key"},
+		ExposedHeaders: []string{"ETag", "Retry-After", "RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Idempotent-Replayed"},
+		MaxAge:         10 * time.Minute,
+	}
+}
+
+// Handler is the middleware function that applies CORS to every route of
+// next. It must wrap the router itself, since preflight requests use the
+// OPTIONS method that routes are not registered for. Requests from origins
+// that are not allowed get no CORS headers, so browsers block them.
+func (m *CORSMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Responses depend on the origin, so caches must keep them apart
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !m.allowedOrigin(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if m.AllowCredentials || !containsFold(m.AllowedOrigins, "*") {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+		if m.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if len(m.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(m.ExposedHeaders, ", "))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Answer the preflight request, allowing it only if the method and
+		// every requested header are allowed
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !containsFold(m.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			if header = strings.TrimSpace(header); header != "" && !containsFold(m.AllowedHeaders, header) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(m.AllowedMethods, ", "))
+		if len(m.AllowedHeaders) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(m.AllowedHeaders, ", "))
+		}
+		if m.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(m.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (m *CORSMiddleware) allowedOrigin(origin string) bool {
+	for _, allowed := range m.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether values includes value, ignoring case.
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+const (
+	// maxIdempotencyKeyLength is the longest Idempotency-Key header accepted.
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize is the largest request body accepted with an Idempotency-Key.
+	maxIdempotentBodySize = 1 << 20
+)
+
+// replayedHeaders are the response headers stored with an idempotent response
+// besides Content-Type. Others, like the rate limit headers, describe the retry.
+var replayedHeaders = []string{"ETag", "Location", "Last-Modified"}
+
+// IdempotencyMiddleware makes requests carrying an Idempotency-Key header
+// safe to retry: the first response for a key is stored and replayed for
+// retries with the same key and body.
+type IdempotencyMiddleware struct {
+	IdempotencyStore models.IdempotencyStore
+	// TTL is how long a key and its response are kept.
+	TTL time.Duration
+}
+
+// NewIdempotencyMiddleware creates a new IdempotencyMiddleware instance.
+func NewIdempotencyMiddleware(idempotencyStore models.IdempotencyStore) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{IdempotencyStore: idempotencyStore, TTL: 24 * time.Hour}
+}
+
+// Idempotent is the middleware function that handles the Idempotency-Key
+// header. Keys are scoped to the authenticated user, or to the client IP of
+// unauthenticated requests. A key reused with a different request is rejected
+// with 422, and one whose first request is still running with 409. Responses
+// with server errors are not stored, so those requests can be retried.
+func (m *IdempotencyMiddleware) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Bad Request",
+				"message": "Idempotency-Key must be at most 255 characters",
+			}, http.StatusBadRequest, w)
+			return
+		}
+
+		var scope models.IdempotencyScope
+		if user, ok := r.Context().Value("user").(*models.User); ok && user != nil {
+			scope.UserID = &user.ID
+		} else {
+			scope.ClientIP = utils.ClientIP(r)
+		}
+
+		// Fingerprint the request, keeping the body for the handler
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				utils.HandleError(map[string]interface{}{
+					"error":   "Request Entity Too Large",
+					"message": "The request body is too large",
+				}, http.StatusRequestEntityTooLarge, w)
+				return
+			}
+			utils.HandleError(map[string]interface{}{
+				"error":   "Bad Request",
+				"message": "Invalid request body",
+			}, http.StatusBadRequest, w)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		record, reserved, err := m.IdempotencyStore.Reserve(scope, key, fingerprint, m.TTL)
+		if err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Internal Server Error",
+				"message": "Error checking idempotency key",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+
+		if !reserved {
+			switch {
+			case record.Fingerprint != fingerprint:
+				utils.HandleError(map[string]interface{}{
+					"error":   "Unprocessable Entity",
+					"message": "Idempotency-Key was already used for a different request",
+				}, http.StatusUnprocessableEntity, w)
+			case record.Response == nil:
+				utils.HandleError(map[string]interface{}{
+					"error":   "Conflict",
+					"message": "A request with this Idempotency-Key is still in progress",
+				}, http.StatusConflict, w)
+			default:
+				if record.Response.ContentType != "" {
+					w.Header().Set("Content-Type", record.Response.ContentType)
+				}
+				for name, value := range record.Response.Headers {
+					w.Header().Set(name, value)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.Response.Status)
+				w.Write(record.Response.Body)
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+
+		if recorder.status >= http.StatusInternalServerError {
+			if err := m.IdempotencyStore.Release(scope, key); err != nil {
+				log.Printf("Error releasing idempotency key: %v", err)
+			}
+			return
+		}
+
+		headers := map[string]string{}
+		for _, name := range replayedHeaders {
+			if value := w.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		err = m.IdempotencyStore.Complete(scope, key, models.IdempotentResponse{
+			Status:      recorder.status,
+			ContentType: w.Header().Get("Content-Type"),
+			Headers:     headers,
+			Body:        recorder.body.Bytes(),
+		})
+		if err != nil {
+			log.Printf("Error storing idempotent response: %v", err)
+		}
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of its status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/proGabby/simple_auth_todo_api/pkg/openapi"
+)
+
+// OpenAPIMiddleware checks the responses of the API against its OpenAPI document.
+type OpenAPIMiddleware struct {
+	Document *openapi.Document
+}
+
+// NewOpenAPIMiddleware creates a new OpenAPIMiddleware instance.
+func NewOpenAPIMiddleware(document *openapi.Document) *OpenAPIMiddleware {
+	return &OpenAPIMiddleware{Document: document}
+}
+
+// ValidateResponses is the router middleware that logs every response whose
+// status or body is not described by the document. Responses are buffered to
+// be checked, so it is meant for development and staging servers.
+func (m *OpenAPIMiddleware) ValidateResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		err = m.Document.ValidateResponse(r.Method, path, recorder.status, w.Header().Get("Content-Type"), recorder.body.Bytes())
+		if err != nil {
+			log.Printf("Response to %s %s does not match the OpenAPI document: %v", r.Method, path, err)
+		}
+	})
+}
@@ -1,24 +1,37 @@
 package middlewares
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/gorilla/mux"
 	"github.com/proGabby/simple_auth_todo_api/pkg/models"
 	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
 )
 
-// PermissionMiddleware handles user authorization based on roles.
+// PermissionMiddleware handles user authorization based on the permissions granted to roles.
 type PermissionMiddleware struct {
-	AuthMiddleware *AuthMiddleware
-	TodoStore      models.TodoStore
+	AuthMiddleware  *AuthMiddleware
+	TodoStore       models.TodoStore
+	PermissionStore models.PermissionStore
+	WorkspaceStore  models.WorkspaceStore
+	ListStore       models.ListStore
 }
 
-func NewPermissionMiddleware(authMiddleware *AuthMiddleware, todoStore models.TodoStore) *PermissionMiddleware {
-	return &PermissionMiddleware{AuthMiddleware: authMiddleware, TodoStore: todoStore}
+func NewPermissionMiddleware(authMiddleware *AuthMiddleware, todoStore models.TodoStore, permissionStore models.PermissionStore, workspaceStore models.WorkspaceStore, listStore models.ListStore) *PermissionMiddleware {
+	return &PermissionMiddleware{
+		AuthMiddleware:  authMiddleware,
+		TodoStore:       todoStore,
+		PermissionStore: permissionStore,
+		WorkspaceStore:  workspaceStore,
+		ListStore:       listStore,
+	}
 }
 
 // Authorize is the middleware function that checks if the user has the required permissions.
-func (m *PermissionMiddleware) Authorize(permittedRoles []string, next http.HandlerFunc) http.HandlerFunc {
+func (m *PermissionMiddleware) Authorize(requiredPermissions []string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Retrieve the user from the request context
 		user, ok := r.Context().Value("user").(*models.User)
@@ -28,7 +41,14 @@ func (m *PermissionMiddleware) Authorize(permittedRoles []string, next http.Hand
 		}
 
 		// Check if the user has the required permissions
-		if !m.hasPermission(user, r, permittedRoles) {
+		if !m.hasPermission(user, requiredPermissions) {
+			if containsPermission(requiredPermissions, models.PermUserAdmin) {
+				RecordAudit(m.AuthMiddleware.AuditStore, r, models.AuditEvent{
+					Event:   models.AuditAdminAccessDenied,
+					Outcome: models.AuditFailure,
+					Target:  r.Method + " " + r.URL.Path,
+				})
+			}
 			utils.HandleError(map[string]interface{}{"error": "Forbidden", "message": "You are not permitted"}, http.StatusForbidden, w)
 			return
 		}
@@ -38,13 +58,197 @@ func (m *PermissionMiddleware) Authorize(permittedRoles []string, next http.Hand
 	}
 }
 
-func (m *PermissionMiddleware) hasPermission(user *models.User, r *http.Request, permittedRoles []string) bool {
-	// Check if the user's role is in the provided roles slice
-	for _, role := range permittedRoles {
-		if user.Role == role {
+// containsPermission reports whether permissions includes permission.
+func containsPermission(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
 			return true
 		}
 	}
-
 	return false
 }
+
+// AuthorizeTodo works like Authorize and additionally checks that the user may
+// act on the todo identified by the request. Reads need read access to the
+// todo, any other method needs edit access; holders of todo:manage may act on
+// every todo.
+func (m *PermissionMiddleware) AuthorizeTodo(requiredPermissions []string, next http.HandlerFunc) http.HandlerFunc {
+	return m.authorizeTodo(requiredPermissions, "", m.TodoStore.GetTodoByID, next)
+}
+
+// AuthorizeTodoReader works like AuthorizeTodo but only requires read access to
+// the todo regardless of the request method, for changes that do not modify it.
+func (m *PermissionMiddleware) AuthorizeTodoReader(requiredPermissions []string, next http.HandlerFunc) http.HandlerFunc {
+	return m.authorizeTodo(requiredPermissions, models.TodoAccessRead, m.TodoStore.GetTodoByID, next)
+}
+
+// AuthorizeTodoOwner works like AuthorizeTodo but requires owner access to the
+// todo regardless of the request method.
+func (m *PermissionMiddleware) AuthorizeTodoOwner(requiredPermissions []string, next http.HandlerFunc) http.HandlerFunc {
+	return m.authorizeTodo(requiredPermissions, models.TodoAccessOwner, m.TodoStore.GetTodoByID, next)
+}
+
+// AuthorizeTrashedTodo works like AuthorizeTodo for todos in the trash, which
+// other todo routes treat as missing, and requires requiredAccess to the todo.
+func (m *PermissionMiddleware) AuthorizeTrashedTodo(requiredPermissions []string, requiredAccess string, next http.HandlerFunc) http.HandlerFunc {
+	return m.authorizeTodo(requiredPermissions, requiredAccess, m.TodoStore.GetDeletedTodoByID, next)
+}
+
+// authorizeTodo checks the user's access to the requested todo, retrieved with
+// getTodo, against requiredAccess, which is derived from the request method when empty.
+func (m *PermissionMiddleware) authorizeTodo(requiredPermissions []string, requiredAccess string, getTodo func(int) (*models.Todo, error), next http.HandlerFunc) http.HandlerFunc {
+	return m.Authorize(requiredPermissions, func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value("user").(*models.User)
+
+		todoID, err := todoIDFromRequest(r)
+		if err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Bad Request",
+				"message": "Invalid todo ID",
+			}, http.StatusBadRequest, w)
+			return
+		}
+
+		todo, err := getTodo(todoID)
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Todo not found"}, http.StatusNotFound, w)
+			return
+		}
+		if err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Internal Server Error",
+				"message": "Error retrieving todo",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+
+		access, err := m.TodoStore.GetAccessLevel(todo, user.ID)
+		if err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Internal Server Error",
+				"message": "Error checking todo access",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+
+		required := requiredAccess
+		if required == "" {
+			required = models.TodoAccessEdit
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				required = models.TodoAccessRead
+			}
+		}
+
+		if !models.TodoAccessAtLeast(access, required) && !m.hasPermission(user, []string{models.PermTodoManage}) {
+			utils.HandleError(map[string]interface{}{"error": "Forbidden", "message": "You are not permitted"}, http.StatusForbidden, w)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// AuthorizeWorkspace works like Authorize and additionally checks that the user
+// holds at least minRole in the workspace identified by the "id" route variable.
+func (m *PermissionMiddleware) AuthorizeWorkspace(requiredPermissions []string, minRole string, next http.HandlerFunc) http.HandlerFunc {
+	return m.Authorize(requiredPermissions, func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value("user").(*models.User)
+
+		workspaceID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Bad Request",
+				"message": "Invalid workspace ID",
+			}, http.StatusBadRequest, w)
+			return
+		}
+
+		role, err := m.WorkspaceStore.GetMemberRole(workspaceID, user.ID)
+		if err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Internal Server Error",
+				"message": "Error checking workspace membership",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+
+		if role == "" {
+			utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "Workspace not found"}, http.StatusNotFound, w)
+			return
+		}
+
+		if !models.WorkspaceRoleAtLeast(role, minRole) {
+			utils.HandleError(map[string]interface{}{"error": "Forbidden", "message": "You are not permitted"}, http.StatusForbidden, w)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// AuthorizeList works like Authorize and additionally checks that the list
+// identified by the "id" route variable belongs to the user.
+func (m *PermissionMiddleware) AuthorizeList(requiredPermissions []string, next http.HandlerFunc) http.HandlerFunc {
+	return m.Authorize(requiredPermissions, func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value("user").(*models.User)
+
+		listID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Bad Request",
+				"message": "Invalid list ID",
+			}, http.StatusBadRequest, w)
+			return
+		}
+
+		list, err := m.ListStore.GetListByID(listID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			utils.HandleError(map[string]interface{}{
+				"error":   "Internal Server Error",
+				"message": "Error retrieving list",
+			}, http.StatusInternalServerError, w)
+			return
+		}
+
+		// Lists are private, so other users' lists are reported as missing
+		if err != nil || list.UserID != user.ID {
+			utils.HandleError(map[string]interface{}{"error": "Not Found", "message": "List not found"}, http.StatusNotFound, w)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+func (m *PermissionMiddleware) hasPermission(user *models.User, requiredPermissions []string) bool {
+	granted, err := m.PermissionStore.GetPermissionsForRole(user.Role)
+	if err != nil {
+		return false
+	}
+
+	// Every required permission must be granted to the user's role
+	for _, required := range requiredPermissions {
+		found := false
+		for _, permission := range granted {
+			if permission == required {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// todoIDFromRequest reads the todo ID from the route variables, falling back to the "id" query parameter.
+func todoIDFromRequest(r *http.Request) (int, error) {
+	id, ok := mux.Vars(r)["id"]
+	if !ok {
+		id = r.URL.Query().Get("id")
+	}
+
+	return strconv.Atoi(id)
+}
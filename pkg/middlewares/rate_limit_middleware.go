@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/proGabby/simple_auth_todo_api/pkg/data/ratelimit"
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+// RateLimitMiddleware limits how often clients may call a group of routes.
+type RateLimitMiddleware struct {
+	Store ratelimit.Store
+}
+
+// NewRateLimitMiddleware creates a new RateLimitMiddleware instance.
+func NewRateLimitMiddleware(store ratelimit.Store) *RateLimitMiddleware {
+	return &RateLimitMiddleware{Store: store}
+}
+
+// Limit is the middleware function that applies policy to the routes of group.
+// Every route wrapped with the same group shares one budget per client.
+// Authenticated requests are counted per user, so Limit must be used inside
+// Authenticate for that, and other requests per client address; wrapping
+// Authenticate in a Limit of another group also limits requests with missing
+// or invalid tokens, which never reach the inner one. Requests over
+// the limit are rejected with 429. The RateLimit-Limit, RateLimit-Remaining
+// and RateLimit-Reset headers report the client's budget on every response.
+func (m *RateLimitMiddleware) Limit(group string, policy ratelimit.Policy, next http.HandlerFunc) http.HandlerFunc {
+	if policy.Disabled() {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := group + ":ip:" + utils.ClientIP(r)
+		if user, ok := r.Context().Value("user").(*models.User); ok && user != nil {
+			key = group + ":user:" + strconv.Itoa(user.ID)
+		}
+
+		result, err := m.Store.Take(r.Context(), key, policy)
+		if err != nil {
+			// Fail open, so that a storage problem does not take the API down
+			log.Printf("Error checking rate limit: %v", err)
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Window)))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			utils.HandleError(map[string]interface{}{
+				"error":   "Too Many Requests",
+				"message": "Rate limit exceeded, try again later",
+			}, http.StatusTooManyRequests, w)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// ceilSeconds rounds d up to whole seconds, as the rate limit headers use.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/proGabby/simple_auth_todo_api/pkg/data/blobstore"
+)
+
+// Attachment is a file attached to a todo. Its contents live in a BlobStore under StorageKey.
+type Attachment struct {
+	ID          int       `json:"id"`
+	TodoID      int       `json:"todo_id"`
+	UserID      int       `json:"user_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AttachmentStore is responsible for interacting with the attachment data in the database.
+type AttachmentStore struct {
+	DB *sql.DB
+}
+
+// NewAttachmentStore creates a new AttachmentStore instance.
+func NewAttachmentStore(db *sql.DB) *AttachmentStore {
+	return &AttachmentStore{DB: db}
+}
+
+const attachmentColumns = "id, todo_id, user_id, filename, content_type, size, storage_key, created_at"
+
+func scanAttachment(row rowScanner) (*Attachment, error) {
+	var a Attachment
+	err := row.Scan(&a.ID, &a.TodoID, &a.UserID, &a.Filename, &a.ContentType, &a.Size, &a.StorageKey, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// GetAttachments retrieves the attachments of a todo, oldest first.
+func (as *AttachmentStore) GetAttachments(todoID int) ([]Attachment, error) {
+	attachments := []Attachment{}
+	query := "SELECT " + attachmentColumns + " FROM todo_attachments WHERE todo_id = $1 ORDER BY created_at, id"
+	rows, err := as.DB.Query(query, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *attachment)
+	}
+
+	return attachments, rows.Err()
+}
+
+// GetAttachmentByID retrieves an attachment of a todo.
+func (as *AttachmentStore) GetAttachmentByID(todoID, attachmentID int) (*Attachment, error) {
+	query := "SELECT " + attachmentColumns + " FROM todo_attachments WHERE id = $1 AND todo_id = $2"
+	return scanAttachment(as.DB.QueryRow(query, attachmentID, todoID))
+}
+
+// CreateAttachment records an attachment whose contents have already been stored.
+func (as *AttachmentStore) CreateAttachment(a Attachment) (*Attachment, error) {
+	query := `INSERT INTO todo_attachments(todo_id, user_id, filename, content_type, size, storage_key)
+		VALUES($1, $2, $3, $4, $5, $6) RETURNING ` + attachmentColumns
+	return scanAttachment(as.DB.QueryRow(query, a.TodoID, a.UserID, a.Filename, a.ContentType, a.Size, a.StorageKey))
+}
+
+// DeleteAttachment deletes an attachment of a todo. Its contents are queued
+// for deletion by the database and removed by PurgeDeletedBlobs.
+func (as *AttachmentStore) DeleteAttachment(todoID, attachmentID int) error {
+	query := "DELETE FROM todo_attachments WHERE id = $1 AND todo_id = $2"
+	_, err := as.DB.Exec(query, attachmentID, todoID)
+	return err
+}
+
+// PurgeDeletedBlobs removes up to limit blobs left behind by deleted
+// attachments, including those of deleted todos, and returns how many were
+// removed. Blobs that fail to delete stay queued for the next run.
+func (as *AttachmentStore) PurgeDeletedBlobs(ctx context.Context, blobs blobstore.BlobStore, limit int) (int, error) {
+	tx, err := as.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Skip keys claimed by a concurrent purge in another process
+	query := "SELECT storage_key FROM attachment_blob_deletions ORDER BY queued_at LIMIT $1 FOR UPDATE SKIP LOCKED"
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	var firstErr error
+	for _, key := range keys {
+		if err := blobs.Delete(ctx, key); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM attachment_blob_deletions WHERE storage_key = $1", key); err != nil {
+			return 0, err
+		}
+		purged++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return purged, firstErr
+}
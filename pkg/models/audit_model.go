@@ -0,0 +1,165 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Audited events.
+const (
+	AuditUserRegister        = "user.register"
+	AuditUserLogin           = "user.login"
+	AuditAuthFailure         = "auth.failure"
+	AuditAdminAccessDenied   = "admin.access_denied"
+	AuditAdminRolePermission = "admin.role_permissions.update"
+	AuditAdminUserRole       = "admin.user_role.update"
+)
+
+// Outcomes of audited events.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent is a security-relevant event: who did what, from where and whether it succeeded.
+type AuditEvent struct {
+	ID      int64  `json:"id"`
+	Event   string `json:"event"`
+	Outcome string `json:"outcome"`
+	// ActorID is the authenticated user, if any. ActorName is their username,
+	// or the username given in a failed login or registration.
+	ActorID   *int                   `json:"actor_id"`
+	ActorName string                 `json:"actor_name"`
+	Target    string                 `json:"target"`
+	IP        string                 `json:"ip"`
+	UserAgent string                 `json:"user_agent"`
+	Details   map[string]interface{} `json:"details"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditFilter narrows down an audit log query. Zero values apply no restriction.
+type AuditFilter struct {
+	Event   string
+	Outcome string
+	ActorID int
+	IP      string
+	Since   *time.Time
+	Until   *time.Time
+	Limit   int
+	Offset  int
+}
+
+// AuditStore is responsible for interacting with the audit log in the database.
+type AuditStore struct {
+	DB *sql.DB
+}
+
+// NewAuditStore creates a new AuditStore instance.
+func NewAuditStore(db *sql.DB) *AuditStore {
+	return &AuditStore{DB: db}
+}
+
+// RecordEvent appends an event to the audit log.
+func (as *AuditStore) RecordEvent(event AuditEvent) error {
+	details := event.Details
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	b, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO audit_events(event, outcome, actor_id, actor_name, target, ip, user_agent, details)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = as.DB.Exec(query, event.Event, event.Outcome, event.ActorID, event.ActorName, event.Target,
+		event.IP, event.UserAgent, string(b))
+	return err
+}
+
+// GetEvents retrieves the audit events matching a filter, most recent first.
+func (as *AuditStore) GetEvents(filter AuditFilter) ([]AuditEvent, error) {
+	events := []AuditEvent{}
+	err := as.EachEvent(filter, func(event AuditEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// EachEvent calls fn with each audit event matching a filter, most recent
+// first, without holding them all in memory. It stops at the first error from fn.
+func (as *AuditStore) EachEvent(filter AuditFilter, fn func(AuditEvent) error) error {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Event != "" {
+		addCondition("event = $%d", filter.Event)
+	}
+	if filter.Outcome != "" {
+		addCondition("outcome = $%d", filter.Outcome)
+	}
+	if filter.ActorID != 0 {
+		addCondition("actor_id = $%d", filter.ActorID)
+	}
+	if filter.IP != "" {
+		addCondition("ip = $%d", filter.IP)
+	}
+	if filter.Since != nil {
+		addCondition("created_at >= $%d", *filter.Since)
+	}
+	if filter.Until != nil {
+		addCondition("created_at < $%d", *filter.Until)
+	}
+
+	query := "SELECT id, event, outcome, actor_id, actor_name, target, ip, user_agent, details, created_at FROM audit_events"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := as.DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event AuditEvent
+		var details []byte
+		err := rows.Scan(&event.ID, &event.Event, &event.Outcome, &event.ActorID, &event.ActorName, &event.Target,
+			&event.IP, &event.UserAgent, &details, &event.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(details, &event.Details); err != nil {
+			return err
+		}
+
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
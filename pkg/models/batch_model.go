@@ -0,0 +1,75 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// TodoBatch applies several todo changes in one transaction. Each change runs
+// under its own savepoint, so a failed change can be undone on its own while
+// the others are kept.
+type TodoBatch struct {
+	ts *TodoStore
+	tx *sql.Tx
+}
+
+// BeginBatch starts a batch of todo changes. The batch must be ended with
+// Commit or Rollback.
+func (ts *TodoStore) BeginBatch() (*TodoBatch, error) {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	return &TodoBatch{ts: ts, tx: tx}, nil
+}
+
+// Apply runs change under a savepoint. If change fails, its effects are undone
+// and its error is returned, leaving the batch usable for further changes.
+func (b *TodoBatch) Apply(change func() error) error {
+	if _, err := b.tx.Exec("SAVEPOINT batch_change"); err != nil {
+		return err
+	}
+
+	if err := change(); err != nil {
+		if _, rollbackErr := b.tx.Exec("ROLLBACK TO SAVEPOINT batch_change"); rollbackErr != nil {
+			return fmt.Errorf("%v; undoing it failed: %w", err, rollbackErr)
+		}
+		return err
+	}
+
+	_, err := b.tx.Exec("RELEASE SAVEPOINT batch_change")
+	return err
+}
+
+// CreateTodo creates a todo as part of the batch, like TodoStore.CreateTodo.
+// The related data of the todo is not loaded.
+func (b *TodoBatch) CreateTodo(todo Todo) (*Todo, error) {
+	return createTodo(b.tx, todo)
+}
+
+// EditTodo edits a todo as part of the batch, like TodoStore.EditTodo. The
+// related data of the todo is not loaded.
+func (b *TodoBatch) EditTodo(todoID int, edit func(TodoFields) (TodoFields, error), userID int, ifVersions []int) (*Todo, error) {
+	return b.ts.editTodo(b.tx, todoID, edit, userID, ifVersions)
+}
+
+// DeleteTodo moves a todo to the trash as part of the batch, like TodoStore.DeleteTodo.
+func (b *TodoBatch) DeleteTodo(todoID, userID int, ifVersions []int) error {
+	return deleteTodo(b.tx, todoID, userID, ifVersions)
+}
+
+// Commit applies the changes that succeeded.
+func (b *TodoBatch) Commit() error {
+	return b.tx.Commit()
+}
+
+// Rollback discards every change of the batch. It does nothing once the batch is committed.
+func (b *TodoBatch) Rollback() error {
+	return b.tx.Rollback()
+}
+
+// LoadTodos fills in the related data of todos returned by a batch.
+func (ts *TodoStore) LoadTodos(todos []Todo) error {
+	return ts.loadTodoDetails(todos)
+}
@@ -0,0 +1,304 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrWIPLimitReached is returned when a todo is moved into a board column that already holds its WIP limit.
+var ErrWIPLimitReached = errors.New("board column is at its WIP limit")
+
+// Board groups the todos of a user, or of one of their lists, into columns by status.
+type Board struct {
+	ID      int           `json:"id"`
+	UserID  int           `json:"user_id"`
+	ListID  *int          `json:"list_id,omitempty"`
+	Name    string        `json:"name"`
+	Columns []BoardColumn `json:"columns"`
+}
+
+// BoardColumn shows the todos with one status. A nil WIPLimit means the column is unlimited.
+type BoardColumn struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	WIPLimit *int   `json:"wip_limit,omitempty"`
+	Todos    []Todo `json:"todos"`
+}
+
+// DefaultBoardColumns are used for boards created without a column configuration.
+var DefaultBoardColumns = []BoardColumn{
+	{Name: "To do", Status: TodoStatusActive},
+	{Name: "In progress", Status: TodoStatusInProgress},
+	{Name: "Done", Status: TodoStatusDone},
+}
+
+// ValidateBoardColumns checks that columns are named, map to distinct statuses
+// and have non-negative WIP limits.
+func ValidateBoardColumns(columns []BoardColumn) error {
+	if len(columns) == 0 {
+		return errors.New("a board needs at least one column")
+	}
+
+	statuses := map[string]bool{}
+	for _, column := range columns {
+		if column.Name == "" || column.Status == "" {
+			return errors.New("every column needs a name and a status")
+		}
+		if statuses[column.Status] {
+			return fmt.Errorf("status %q is mapped to more than one column", column.Status)
+		}
+		if column.WIPLimit != nil && *column.WIPLimit < 0 {
+			return errors.New("wip_limit must not be negative")
+		}
+		statuses[column.Status] = true
+	}
+
+	return nil
+}
+
+// ColumnByID returns the board's column with the given ID, if any.
+func (b *Board) ColumnByID(columnID int) *BoardColumn {
+	for i := range b.Columns {
+		if b.Columns[i].ID == columnID {
+			return &b.Columns[i]
+		}
+	}
+
+	return nil
+}
+
+// Includes reports whether a todo is shown on the board.
+func (b *Board) Includes(todo *Todo) bool {
+	if b.ListID != nil {
+		return todo.ListID != nil && *todo.ListID == *b.ListID
+	}
+
+	return todo.UserID == b.UserID && todo.WorkspaceID == nil
+}
+
+// BoardStore is responsible for interacting with the board data in the database.
+type BoardStore struct {
+	DB *sql.DB
+}
+
+// NewBoardStore creates a new BoardStore instance.
+func NewBoardStore(db *sql.DB) *BoardStore {
+	return &BoardStore{DB: db}
+}
+
+// CreateBoard creates a new board with the given columns.
+func (bs *BoardStore) CreateBoard(userID int, name string, listID *int, columns []BoardColumn) (*Board, error) {
+	tx, err := bs.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var boardID int
+	query := "INSERT INTO boards(user_id, list_id, name) VALUES($1, $2, $3) RETURNING id"
+	if err := tx.QueryRow(query, userID, listID, name).Scan(&boardID); err != nil {
+		return nil, err
+	}
+
+	if err := insertBoardColumns(tx, boardID, columns); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return bs.GetBoardByID(boardID)
+}
+
+// GetBoardsByUserID retrieves a user's boards without their columns.
+func (bs *BoardStore) GetBoardsByUserID(userID int) ([]Board, error) {
+	boards := []Board{}
+	query := "SELECT id, user_id, list_id, name FROM boards WHERE user_id = $1 ORDER BY name, id"
+	rows, err := bs.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		board := Board{Columns: []BoardColumn{}}
+		if err := rows.Scan(&board.ID, &board.UserID, &board.ListID, &board.Name); err != nil {
+			return nil, err
+		}
+		boards = append(boards, board)
+	}
+
+	return boards, rows.Err()
+}
+
+// GetBoardByID retrieves a board and its columns.
+func (bs *BoardStore) GetBoardByID(boardID int) (*Board, error) {
+	board := Board{Columns: []BoardColumn{}}
+	query := "SELECT id, user_id, list_id, name FROM boards WHERE id = $1"
+	err := bs.DB.QueryRow(query, boardID).Scan(&board.ID, &board.UserID, &board.ListID, &board.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	query = "SELECT id, name, status, wip_limit FROM board_columns WHERE board_id = $1 ORDER BY position, id"
+	rows, err := bs.DB.Query(query, boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var column BoardColumn
+		if err := rows.Scan(&column.ID, &column.Name, &column.Status, &column.WIPLimit); err != nil {
+			return nil, err
+		}
+		board.Columns = append(board.Columns, column)
+	}
+
+	return &board, rows.Err()
+}
+
+// SetColumns replaces the columns of a board.
+func (bs *BoardStore) SetColumns(boardID int, columns []BoardColumn) (*Board, error) {
+	tx, err := bs.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM board_columns WHERE board_id = $1", boardID); err != nil {
+		return nil, err
+	}
+
+	if err := insertBoardColumns(tx, boardID, columns); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return bs.GetBoardByID(boardID)
+}
+
+// DeleteBoard deletes a board. The todos shown on it are not affected.
+func (bs *BoardStore) DeleteBoard(boardID int) error {
+	query := "DELETE FROM boards WHERE id = $1"
+	_, err := bs.DB.Exec(query, boardID)
+	return err
+}
+
+func insertBoardColumns(q queryer, boardID int, columns []BoardColumn) error {
+	query := "INSERT INTO board_columns(board_id, name, status, wip_limit, position) VALUES($1, $2, $3, $4, $5)"
+	for i, column := range columns {
+		if _, err := q.Exec(query, boardID, column.Name, column.Status, column.WIPLimit, i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// boardTodoCondition matches todos t shown on board b.
+const boardTodoCondition = `((b.list_id IS NULL AND t.user_id = b.user_id AND t.workspace_id IS NULL) OR t.list_id = b.list_id)`
+
+// checkWIPLimits fails with ErrWIPLimitReached if moving todoID to status would
+// exceed the WIP limit of that status's column on any board showing the todo.
+// The columns involved are locked so concurrent moves are counted one at a time.
+func checkWIPLimits(q queryer, todoID int, status string) error {
+	query := `SELECT c.id FROM board_columns c
+		JOIN boards b ON b.id = c.board_id
+		JOIN todos t ON ` + boardTodoCondition + `
+		WHERE t.id = $1 AND c.status = $2 AND c.wip_limit IS NOT NULL
+		ORDER BY c.id FOR UPDATE OF c`
+	rows, err := q.Query(query, todoID, status)
+	if err != nil {
+		return err
+	}
+	rows.Close()
+
+	var name string
+	var limit int
+	query = `SELECT c.name, c.wip_limit FROM board_columns c
+		JOIN boards b ON b.id = c.board_id
+		JOIN todos todo ON todo.id = $1
+		WHERE c.status = $2 AND c.wip_limit IS NOT NULL
+		AND ((b.list_id IS NULL AND todo.user_id = b.user_id AND todo.workspace_id IS NULL) OR todo.list_id = b.list_id)
+		AND c.wip_limit <= (
+			SELECT COUNT(*) FROM todos t WHERE t.status = c.status AND t.id <> todo.id AND t.deleted_at IS NULL AND ` + boardTodoCondition + `
+		)
+		LIMIT 1`
+	err = q.QueryRow(query, todoID, status).Scan(&name, &limit)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: %q allows %d todos", ErrWIPLimitReached, name, limit)
+}
+
+// MoveTodoToColumn moves a todo into a board column by giving it the column's
+// status, optionally placing it before or after another todo (referenceID 0
+// keeps its position). Both changes are applied in one transaction and the
+// status change is recorded in the todo's history as made by userID.
+func (ts *TodoStore) MoveTodoToColumn(todoID int, status string, referenceID int, after bool, userID int) (*Todo, error) {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = ts.changeTodo(tx, todoID, func(todo *Todo) error {
+		todo.Status = status
+		return nil
+	}, userID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if referenceID != 0 {
+		if err := moveTodo(tx, todoID, referenceID, after); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ts.GetTodoByID(todoID)
+}
+
+// LoadBoardTodos fills the columns of a board with the todos it shows, in
+// position order. Todos whose status has no column are left out.
+func (ts *TodoStore) LoadBoardTodos(board *Board) error {
+	var todos []Todo
+	var err error
+	if board.ListID != nil {
+		todos, err = ts.GetTodosByListID(*board.ListID, TodoFilter{})
+	} else {
+		todos, err = ts.GetTodosByUserID(board.UserID, TodoFilter{})
+	}
+	if err != nil {
+		return err
+	}
+
+	columns := make(map[string]*BoardColumn, len(board.Columns))
+	for i := range board.Columns {
+		board.Columns[i].Todos = []Todo{}
+		columns[board.Columns[i].Status] = &board.Columns[i]
+	}
+
+	for _, todo := range todos {
+		if column, ok := columns[todo.Status]; ok {
+			column.Todos = append(column.Todos, todo)
+		}
+	}
+
+	return nil
+}
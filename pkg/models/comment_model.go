@@ -0,0 +1,114 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// MaxCommentLength is the longest comment body accepted, in characters.
+const MaxCommentLength = 10000
+
+// ErrCommentEditWindowClosed is returned when a comment is edited after its edit window has passed.
+var ErrCommentEditWindowClosed = errors.New("comments can no longer be edited")
+
+// Comment is a message left on a todo. The body is markdown and is stored as written.
+type Comment struct {
+	ID        int        `json:"id"`
+	TodoID    int        `json:"todo_id"`
+	UserID    int        `json:"user_id"`
+	Author    string     `json:"author"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+}
+
+// CommentStore is responsible for interacting with the comment data in the database.
+type CommentStore struct {
+	DB *sql.DB
+	// EditWindow is how long after posting a comment can be edited.
+	EditWindow time.Duration
+}
+
+// NewCommentStore creates a new CommentStore instance.
+func NewCommentStore(db *sql.DB) *CommentStore {
+	return &CommentStore{DB: db, EditWindow: 15 * time.Minute}
+}
+
+const commentQuery = `SELECT c.id, c.todo_id, c.user_id, u.username, c.body, c.created_at, c.edited_at
+	FROM todo_comments c JOIN users u ON u.id = c.user_id`
+
+func scanComment(row rowScanner) (*Comment, error) {
+	var comment Comment
+	err := row.Scan(&comment.ID, &comment.TodoID, &comment.UserID, &comment.Author, &comment.Body, &comment.CreatedAt, &comment.EditedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &comment, nil
+}
+
+// GetComments retrieves a page of the comments on a todo, oldest first. Deleted comments are left out.
+func (cs *CommentStore) GetComments(todoID, limit, offset int) ([]Comment, error) {
+	comments := []Comment{}
+	query := commentQuery + " WHERE c.todo_id = $1 AND c.deleted_at IS NULL ORDER BY c.created_at, c.id LIMIT $2 OFFSET $3"
+	rows, err := cs.DB.Query(query, todoID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, *comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// GetCommentByID retrieves a comment on a todo. Deleted comments are not found.
+func (cs *CommentStore) GetCommentByID(todoID, commentID int) (*Comment, error) {
+	query := commentQuery + " WHERE c.id = $1 AND c.todo_id = $2 AND c.deleted_at IS NULL"
+	return scanComment(cs.DB.QueryRow(query, commentID, todoID))
+}
+
+// CreateComment adds a comment by a user to a todo.
+func (cs *CommentStore) CreateComment(todoID, userID int, body string) (*Comment, error) {
+	var commentID int
+	query := "INSERT INTO todo_comments(todo_id, user_id, body) VALUES($1, $2, $3) RETURNING id"
+	if err := cs.DB.QueryRow(query, todoID, userID, body).Scan(&commentID); err != nil {
+		return nil, err
+	}
+
+	return cs.GetCommentByID(todoID, commentID)
+}
+
+// UpdateComment replaces the body of a comment, failing with
+// ErrCommentEditWindowClosed once EditWindow has passed since it was posted.
+func (cs *CommentStore) UpdateComment(todoID, commentID int, body string) (*Comment, error) {
+	query := `UPDATE todo_comments SET body = $3, edited_at = now()
+		WHERE id = $1 AND todo_id = $2 AND deleted_at IS NULL
+		AND created_at >= now() - make_interval(secs => $4)`
+	result, err := cs.DB.Exec(query, commentID, todoID, body, cs.EditWindow.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	if updated, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if updated == 0 {
+		return nil, ErrCommentEditWindowClosed
+	}
+
+	return cs.GetCommentByID(todoID, commentID)
+}
+
+// DeleteComment marks a comment as deleted. Its row is kept but it is no longer listed.
+func (cs *CommentStore) DeleteComment(todoID, commentID int) error {
+	query := "UPDATE todo_comments SET deleted_at = now() WHERE id = $1 AND todo_id = $2 AND deleted_at IS NULL"
+	_, err := cs.DB.Exec(query, commentID, todoID)
+	return err
+}
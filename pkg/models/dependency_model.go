@@ -0,0 +1,209 @@
+package models
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrDependencyCycle is returned when a dependency would make a todo wait on itself.
+	ErrDependencyCycle = errors.New("dependency would create a cycle")
+	// ErrTodoBlocked is returned when a todo with open blockers is started or completed.
+	ErrTodoBlocked = errors.New("todo is blocked by todos that are not done")
+)
+
+// openBlockersQuery selects the dependencies of todo $1 whose status is not $2
+// (done). Todos in the trash do not block.
+const openBlockersQuery = `SELECT 1 FROM todo_dependencies d
+	JOIN todos b ON b.id = d.depends_on_id
+	WHERE d.todo_id = $1 AND b.status <> $2 AND b.deleted_at IS NULL`
+
+// AddDependency records that todoID cannot start until dependsOnID is done.
+func (ts *TodoStore) AddDependency(todoID, dependsOnID int) (*Todo, error) {
+	if todoID == dependsOnID {
+		return nil, ErrDependencyCycle
+	}
+
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Serialize dependency changes so two concurrent additions cannot close a cycle
+	if _, err := tx.Exec("LOCK TABLE todo_dependencies IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return nil, err
+	}
+
+	// A cycle exists if dependsOnID already waits, directly or not, on todoID
+	var cycle bool
+	query := `WITH RECURSIVE upstream AS (
+			SELECT depends_on_id FROM todo_dependencies WHERE todo_id = $1
+			UNION
+			SELECT d.depends_on_id FROM todo_dependencies d
+			JOIN upstream u ON d.todo_id = u.depends_on_id
+		)
+		SELECT EXISTS (SELECT 1 FROM upstream WHERE depends_on_id = $2)`
+	if err := tx.QueryRow(query, dependsOnID, todoID).Scan(&cycle); err != nil {
+		return nil, err
+	}
+	if cycle {
+		return nil, ErrDependencyCycle
+	}
+
+	query = "INSERT INTO todo_dependencies(todo_id, depends_on_id) VALUES($1, $2) ON CONFLICT DO NOTHING"
+	if _, err := tx.Exec(query, todoID, dependsOnID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ts.GetTodoByID(todoID)
+}
+
+// RemoveDependency removes the dependency of todoID on dependsOnID.
+func (ts *TodoStore) RemoveDependency(todoID, dependsOnID int) (*Todo, error) {
+	query := "DELETE FROM todo_dependencies WHERE todo_id = $1 AND depends_on_id = $2"
+	if _, err := ts.DB.Exec(query, todoID, dependsOnID); err != nil {
+		return nil, err
+	}
+
+	return ts.GetTodoByID(todoID)
+}
+
+// HideUnreadableDependencies removes the todos userID cannot read from the
+// BlockedBy and Blocks lists of todos, so that showing a todo does not reveal
+// the IDs of other users' todos. Blocked still accounts for every dependency.
+func (ts *TodoStore) HideUnreadableDependencies(todos []Todo, userID int) error {
+	var ids []int64
+	for _, todo := range todos {
+		for _, id := range todo.BlockedBy {
+			ids = append(ids, int64(id))
+		}
+		for _, id := range todo.Blocks {
+			ids = append(ids, int64(id))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	readable := map[int]bool{}
+	query := "SELECT t.id FROM todos t WHERE t.id = ANY($2) AND " + accessibleTodoCondition
+	rows, err := ts.DB.Query(query, userID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		readable[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range todos {
+		todos[i].BlockedBy = readableIDs(todos[i].BlockedBy, readable)
+		todos[i].Blocks = readableIDs(todos[i].Blocks, readable)
+	}
+
+	return nil
+}
+
+func readableIDs(ids []int, readable map[int]bool) []int {
+	filtered := []int{}
+	for _, id := range ids {
+		if readable[id] {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered
+}
+
+// GetNextActionable retrieves a user's open personal todos in dependency order:
+// every todo comes after the todos it depends on, so the unblocked ones lead.
+// Todos at the same depth are ordered by due date.
+func (ts *TodoStore) GetNextActionable(userID int) ([]Todo, error) {
+	conditions := []string{"user_id = $1", "workspace_id IS NULL", "status <> $2"}
+	todos, err := ts.listTodos(conditions, []interface{}{userID, TodoStatusDone}, TodoFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	return orderByDependencies(todos), nil
+}
+
+// orderByDependencies sorts todos topologically with Kahn's algorithm, layer by
+// layer. Dependencies on todos outside the slice do not affect the order.
+func orderByDependencies(todos []Todo) []Todo {
+	index := make(map[int]int, len(todos))
+	for i, todo := range todos {
+		index[todo.ID] = i
+	}
+
+	waiting := make([]int, len(todos))
+	var layer []int
+	for i, todo := range todos {
+		for _, id := range todo.BlockedBy {
+			if _, ok := index[id]; ok {
+				waiting[i]++
+			}
+		}
+		if waiting[i] == 0 {
+			layer = append(layer, i)
+		}
+	}
+
+	ordered := make([]Todo, 0, len(todos))
+	placed := make([]bool, len(todos))
+	for len(layer) > 0 {
+		sort.SliceStable(layer, func(a, b int) bool {
+			return dueBefore(todos[layer[a]], todos[layer[b]])
+		})
+
+		var next []int
+		for _, i := range layer {
+			ordered = append(ordered, todos[i])
+			placed[i] = true
+			for _, id := range todos[i].Blocks {
+				if j, ok := index[id]; ok {
+					waiting[j]--
+					if waiting[j] == 0 {
+						next = append(next, j)
+					}
+				}
+			}
+		}
+		layer = next
+	}
+
+	// Cycles are rejected when dependencies are added, but never drop a todo
+	for i := range todos {
+		if !placed[i] {
+			ordered = append(ordered, todos[i])
+		}
+	}
+
+	return ordered
+}
+
+// dueBefore orders todos by due date, placing todos without one last.
+func dueBefore(a, b Todo) bool {
+	switch {
+	case a.DueAt == nil:
+		return false
+	case b.DueAt == nil:
+		return true
+	default:
+		return a.DueAt.Before(*b.DueAt)
+	}
+}
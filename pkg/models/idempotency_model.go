@@ -0,0 +1,129 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// IdempotentResponse is the response stored for an idempotency key and replayed on retries.
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	// Headers are the other response headers replayed, such as ETag and Location.
+	Headers map[string]string
+	Body    []byte
+}
+
+// IdempotencyScope is the namespace of idempotency keys: the user of
+// authenticated requests, or the client IP of unauthenticated ones.
+type IdempotencyScope struct {
+	UserID   *int
+	ClientIP string
+}
+
+// scopeCondition matches the idempotency keys of the scope in $1 and $2.
+const scopeCondition = "COALESCE(user_id, 0) = COALESCE($1, 0) AND client_ip = $2"
+
+// IdempotencyRecord is an idempotency key in use: the fingerprint of the
+// request that first used it and, once that request has finished, its response.
+type IdempotencyRecord struct {
+	Fingerprint string
+	Response    *IdempotentResponse
+}
+
+// IdempotencyStore is responsible for interacting with the idempotency key data in the database.
+type IdempotencyStore struct {
+	DB *sql.DB
+}
+
+// NewIdempotencyStore creates a new IdempotencyStore instance.
+func NewIdempotencyStore(db *sql.DB) *IdempotencyStore {
+	return &IdempotencyStore{DB: db}
+}
+
+// Reserve claims an idempotency key of a scope for a request with the given
+// fingerprint, for ttl. If the key is already in use it returns the existing
+// record and false instead.
+func (s *IdempotencyStore) Reserve(scope IdempotencyScope, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	// An expired key can be used again
+	query := "DELETE FROM idempotency_keys WHERE " + scopeCondition + " AND key = $3 AND expires_at < now()"
+	if _, err := tx.Exec(query, scope.UserID, scope.ClientIP, key); err != nil {
+		return nil, false, err
+	}
+
+	query = `INSERT INTO idempotency_keys(user_id, client_ip, key, fingerprint, expires_at)
+		VALUES($1, $2, $3, $4, now() + make_interval(secs => $5)) ON CONFLICT DO NOTHING`
+	result, err := tx.Exec(query, scope.UserID, scope.ClientIP, key, fingerprint, ttl.Seconds())
+	if err != nil {
+		return nil, false, err
+	}
+	if inserted, err := result.RowsAffected(); err != nil {
+		return nil, false, err
+	} else if inserted == 1 {
+		return nil, true, tx.Commit()
+	}
+
+	var record IdempotencyRecord
+	var status sql.NullInt64
+	var contentType string
+	var headers, body []byte
+	query = "SELECT fingerprint, status, content_type, headers, body FROM idempotency_keys WHERE " + scopeCondition + " AND key = $3"
+	if err := tx.QueryRow(query, scope.UserID, scope.ClientIP, key).Scan(&record.Fingerprint, &status, &contentType, &headers, &body); err != nil {
+		return nil, false, err
+	}
+	if status.Valid {
+		record.Response = &IdempotentResponse{Status: int(status.Int64), ContentType: contentType, Body: body}
+		if err := json.Unmarshal(headers, &record.Response.Headers); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return &record, false, tx.Commit()
+}
+
+// Complete stores the response to the request that reserved an idempotency key.
+func (s *IdempotencyStore) Complete(scope IdempotencyScope, key string, response IdempotentResponse) error {
+	headers, err := json.Marshal(response.Headers)
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE idempotency_keys SET status = $4, content_type = $5, headers = $6, body = $7 WHERE " + scopeCondition + " AND key = $3"
+	result, err := s.DB.Exec(query, scope.UserID, scope.ClientIP, key, response.Status, response.ContentType, headers, response.Body)
+	if err != nil {
+		return err
+	}
+
+	if updated, err := result.RowsAffected(); err != nil {
+		return err
+	} else if updated == 0 {
+		return errors.New("idempotency key is no longer reserved")
+	}
+
+	return nil
+}
+
+// Release frees an idempotency key whose request did not complete, so that it can be retried.
+func (s *IdempotencyStore) Release(scope IdempotencyScope, key string) error {
+	query := "DELETE FROM idempotency_keys WHERE " + scopeCondition + " AND key = $3"
+	_, err := s.DB.Exec(query, scope.UserID, scope.ClientIP, key)
+	return err
+}
+
+// PurgeExpired deletes expired idempotency keys and returns how many were deleted.
+func (s *IdempotencyStore) PurgeExpired() (int64, error) {
+	result, err := s.DB.Exec("DELETE FROM idempotency_keys WHERE expires_at < now()")
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
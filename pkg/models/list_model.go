@@ -0,0 +1,111 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+)
+
+// ErrListNotOwned is returned when a todo would be placed in a list of another user.
+var ErrListNotOwned = errors.New("the list belongs to another user")
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// IsValidColor reports whether color is empty or a #rrggbb hex color.
+func IsValidColor(color string) bool {
+	return color == "" || colorPattern.MatchString(color)
+}
+
+// List represents a named collection of a user's todos.
+type List struct {
+	ID       int    `json:"id"`
+	UserID   int    `json:"user_id"`
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	Archived bool   `json:"archived"`
+	Position int    `json:"position"`
+}
+
+// ListUpdate holds the list fields to change. Nil fields are left untouched.
+type ListUpdate struct {
+	Name     *string `json:"name"`
+	Color    *string `json:"color"`
+	Archived *bool   `json:"archived"`
+	Position *int    `json:"position"`
+}
+
+// ListStore is responsible for interacting with the list data in the database.
+type ListStore struct {
+	DB *sql.DB
+}
+
+// NewListStore creates a new ListStore instance.
+func NewListStore(db *sql.DB) *ListStore {
+	return &ListStore{DB: db}
+}
+
+const listColumns = "id, user_id, name, color, archived, position"
+
+func scanList(row rowScanner) (*List, error) {
+	var list List
+	err := row.Scan(&list.ID, &list.UserID, &list.Name, &list.Color, &list.Archived, &list.Position)
+	if err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// GetListsByUserID retrieves a user's lists ordered by position. Archived lists
+// are only included when includeArchived is set.
+func (ls *ListStore) GetListsByUserID(userID int, includeArchived bool) ([]List, error) {
+	lists := []List{}
+	query := "SELECT " + listColumns + " FROM lists WHERE user_id = $1 AND (NOT archived OR $2) ORDER BY position, id"
+	rows, err := ls.DB.Query(query, userID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, *list)
+	}
+
+	return lists, rows.Err()
+}
+
+// GetListByID retrieves a list by its ID.
+func (ls *ListStore) GetListByID(listID int) (*List, error) {
+	query := "SELECT " + listColumns + " FROM lists WHERE id = $1"
+	return scanList(ls.DB.QueryRow(query, listID))
+}
+
+// CreateList creates a new list placed after the user's existing lists.
+func (ls *ListStore) CreateList(userID int, name, color string) (*List, error) {
+	query := `INSERT INTO lists(user_id, name, color, position)
+		VALUES($1, $2, $3, (SELECT COALESCE(MAX(position), 0) + 1 FROM lists WHERE user_id = $1))
+		RETURNING ` + listColumns
+	return scanList(ls.DB.QueryRow(query, userID, name, color))
+}
+
+// UpdateList applies the non-nil fields of update to a list.
+func (ls *ListStore) UpdateList(listID int, update ListUpdate) (*List, error) {
+	query := `UPDATE lists SET
+		name = COALESCE($2, name),
+		color = COALESCE($3, color),
+		archived = COALESCE($4, archived),
+		position = COALESCE($5, position)
+		WHERE id = $1 RETURNING ` + listColumns
+	return scanList(ls.DB.QueryRow(query, listID, update.Name, update.Color, update.Archived, update.Position))
+}
+
+// DeleteList deletes a list. Its todos are kept and no longer belong to a list.
+func (ls *ListStore) DeleteList(listID int) error {
+	query := "DELETE FROM lists WHERE id = $1"
+	_, err := ls.DB.Exec(query, listID)
+	return err
+}
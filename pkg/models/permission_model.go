@@ -0,0 +1,142 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Permissions checked by PermissionMiddleware.
+const (
+	PermTodoRead   = "todo:read"
+	PermTodoWrite  = "todo:write"
+	PermTodoDelete = "todo:delete"
+	PermTodoManage = "todo:manage" // act on todos owned by other users
+	PermUserAdmin  = "user:admin"
+)
+
+// ErrLastAdmin is returned when a change would leave no user with PermUserAdmin.
+var ErrLastAdmin = errors.New("at least one user must keep the " + PermUserAdmin + " permission")
+
+// AllPermissions lists every permission known to the system.
+var AllPermissions = []string{
+	PermTodoRead,
+	PermTodoWrite,
+	PermTodoDelete,
+	PermTodoManage,
+	PermUserAdmin,
+}
+
+// IsValidPermission reports whether permission is a known permission name.
+func IsValidPermission(permission string) bool {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PermissionStore is responsible for interacting with the role permission data in the database.
+type PermissionStore struct {
+	DB *sql.DB
+}
+
+// NewPermissionStore creates a new PermissionStore instance.
+func NewPermissionStore(db *sql.DB) *PermissionStore {
+	return &PermissionStore{DB: db}
+}
+
+// GetPermissionsForRole retrieves the permissions granted to a role.
+func (ps *PermissionStore) GetPermissionsForRole(role string) ([]string, error) {
+	permissions := []string{}
+	query := "SELECT permission FROM role_permissions WHERE role = $1 ORDER BY permission"
+	rows, err := ps.DB.Query(query, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, rows.Err()
+}
+
+// GetRolePermissions retrieves the full role to permission mapping.
+func (ps *PermissionStore) GetRolePermissions() (map[string][]string, error) {
+	rolePermissions := map[string][]string{}
+	query := "SELECT role, permission FROM role_permissions ORDER BY role, permission"
+	rows, err := ps.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role, permission string
+		if err := rows.Scan(&role, &permission); err != nil {
+			return nil, err
+		}
+		rolePermissions[role] = append(rolePermissions[role], permission)
+	}
+
+	return rolePermissions, rows.Err()
+}
+
+// SetRolePermissions replaces the permissions granted to a role.
+func (ps *PermissionStore) SetRolePermissions(role string, permissions []string) error {
+	tx, err := ps.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockAdmins(tx); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role = $1", role); err != nil {
+		return err
+	}
+
+	for _, permission := range permissions {
+		query := "INSERT INTO role_permissions(role, permission) VALUES($1, $2) ON CONFLICT DO NOTHING"
+		if _, err := tx.Exec(query, role, permission); err != nil {
+			return err
+		}
+	}
+
+	if err := ensureAdmin(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockAdmins serializes the changes that can take PermUserAdmin away from
+// users, so that two of them cannot each remove a different last admin.
+func lockAdmins(tx *sql.Tx) error {
+	_, err := tx.Exec("LOCK TABLE role_permissions IN SHARE ROW EXCLUSIVE MODE")
+	return err
+}
+
+// ensureAdmin fails if no user holds a role granting PermUserAdmin anymore.
+func ensureAdmin(tx *sql.Tx) error {
+	var admins int
+	query := `SELECT COUNT(*) FROM users u
+		JOIN role_permissions rp ON rp.role = u.role AND rp.permission = $1`
+	if err := tx.QueryRow(query, PermUserAdmin).Scan(&admins); err != nil {
+		return err
+	}
+	if admins == 0 {
+		return ErrLastAdmin
+	}
+
+	return nil
+}
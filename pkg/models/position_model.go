@@ -0,0 +1,133 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+)
+
+const (
+	// positionSpacing is the gap left between todos when positions are assigned or rebalanced.
+	positionSpacing = 1024
+	// minPositionGap is the smallest gap split by a move before the scope is rebalanced.
+	minPositionGap = 1e-6
+)
+
+// ErrInvalidMove is returned when a todo is moved relative to a todo outside its own collection.
+var ErrInvalidMove = errors.New("todos can only be moved relative to todos in the same collection")
+
+// Advisory lock namespaces for positionScope.
+const (
+	personalScopeLock  = 1
+	workspaceScopeLock = 2
+)
+
+// positionScope returns the condition selecting the collection a todo is ordered in (the
+// owner's personal todos or its workspace's todos), its argument and an advisory lock key.
+func positionScope(todo *Todo) (condition string, arg int, lockNamespace int) {
+	if todo.WorkspaceID != nil {
+		return "workspace_id = $1", *todo.WorkspaceID, workspaceScopeLock
+	}
+
+	return "user_id = $1 AND workspace_id IS NULL", todo.UserID, personalScopeLock
+}
+
+// MoveTodo places a todo directly before or after a reference todo in the same
+// collection. The new position is the midpoint between the reference and its
+// neighbour; when positions have become too dense to split, the whole
+// collection is renumbered first. Moves within a collection are serialized.
+func (ts *TodoStore) MoveTodo(todoID, referenceID int, after bool) (*Todo, error) {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := moveTodo(tx, todoID, referenceID, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ts.GetTodoByID(todoID)
+}
+
+// moveTodo implements MoveTodo within the caller's transaction.
+func moveTodo(q queryer, todoID, referenceID int, after bool) error {
+	if todoID == referenceID {
+		return ErrInvalidMove
+	}
+
+	todo, err := scanTodo(q.QueryRow("SELECT "+todoColumns+" FROM todos WHERE id = $1 AND deleted_at IS NULL", todoID))
+	if err != nil {
+		return err
+	}
+
+	scope, scopeArg, lockNamespace := positionScope(todo)
+	if _, err := q.Exec("SELECT pg_advisory_xact_lock($1, $2)", lockNamespace, scopeArg); err != nil {
+		return err
+	}
+
+	reference, err := scanTodo(q.QueryRow("SELECT "+todoColumns+" FROM todos WHERE id = $1 AND deleted_at IS NULL", referenceID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidMove
+	}
+	if err != nil {
+		return err
+	}
+
+	if referenceScope, referenceArg, _ := positionScope(reference); referenceScope != scope || referenceArg != scopeArg {
+		return ErrInvalidMove
+	}
+
+	// The neighbour is the closest todo on the far side of the reference
+	neighbourQuery := "SELECT MAX(position) FROM todos WHERE " + scope + " AND position < $2 AND id <> $3 AND deleted_at IS NULL"
+	if after {
+		neighbourQuery = "SELECT MIN(position) FROM todos WHERE " + scope + " AND position > $2 AND id <> $3 AND deleted_at IS NULL"
+	}
+
+	var position float64
+	for attempt := 0; ; attempt++ {
+		var referencePosition float64
+		err := q.QueryRow("SELECT position FROM todos WHERE id = $1", referenceID).Scan(&referencePosition)
+		if err != nil {
+			return err
+		}
+
+		var neighbour sql.NullFloat64
+		if err := q.QueryRow(neighbourQuery, scopeArg, referencePosition, todoID).Scan(&neighbour); err != nil {
+			return err
+		}
+
+		if !neighbour.Valid {
+			position = referencePosition - positionSpacing
+			if after {
+				position = referencePosition + positionSpacing
+			}
+			break
+		}
+
+		if math.Abs(neighbour.Float64-referencePosition) >= minPositionGap || attempt > 0 {
+			position = (referencePosition + neighbour.Float64) / 2
+			break
+		}
+
+		if err := rebalancePositions(q, scope, scopeArg); err != nil {
+			return err
+		}
+	}
+
+	_, err = q.Exec("UPDATE todos SET position = $2 WHERE id = $1", todoID, position)
+	return err
+}
+
+// rebalancePositions renumbers the todos of a collection evenly, keeping their order.
+func rebalancePositions(q queryer, scope string, scopeArg int) error {
+	query := `UPDATE todos t SET position = ordered.rank * $2
+		FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY position, id) AS rank FROM todos WHERE ` + scope + `) ordered
+		WHERE t.id = ordered.id`
+	_, err := q.Exec(query, scopeArg, positionSpacing)
+	return err
+}
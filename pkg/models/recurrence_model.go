@@ -0,0 +1,245 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Recurrence frequencies.
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+)
+
+// weekdayCodes maps RRULE-style weekday codes to time.Weekday.
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Recurrence describes how a todo repeats, modelled on the iCalendar RRULE.
+// Completing an occurrence of a recurring todo creates the next one.
+type Recurrence struct {
+	Frequency string `json:"frequency"`
+	// Interval repeats every Interval days, weeks or months. Zero means 1.
+	Interval int `json:"interval,omitempty"`
+	// ByWeekday restricts weekly recurrences to the given days (MO, TU, ... SU).
+	ByWeekday []string `json:"by_weekday,omitempty"`
+	// Until stops the series after the given time.
+	Until *time.Time `json:"until,omitempty"`
+	// Count stops the series after the given number of occurrences.
+	Count int `json:"count,omitempty"`
+}
+
+// Validate checks that the recurrence rule is well formed.
+func (rec Recurrence) Validate() error {
+	switch rec.Frequency {
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly:
+	default:
+		return errors.New("frequency must be daily, weekly or monthly")
+	}
+
+	if rec.Interval < 0 {
+		return errors.New("interval must not be negative")
+	}
+	if rec.Count < 0 {
+		return errors.New("count must not be negative")
+	}
+
+	if len(rec.ByWeekday) > 0 && rec.Frequency != FrequencyWeekly {
+		return errors.New("by_weekday is only supported for weekly recurrences")
+	}
+	for _, code := range rec.ByWeekday {
+		if _, ok := weekdayCodes[code]; !ok {
+			return fmt.Errorf("unknown weekday %q", code)
+		}
+	}
+
+	return nil
+}
+
+// Next computes the due date of the occurrence following one due at from,
+// which is the given occurrence number in the series. It returns false once
+// the series has ended through Count or Until.
+func (rec Recurrence) Next(from time.Time, occurrence int) (time.Time, bool) {
+	if rec.Count > 0 && occurrence >= rec.Count {
+		return time.Time{}, false
+	}
+
+	interval := rec.Interval
+	if interval == 0 {
+		interval = 1
+	}
+
+	var next time.Time
+	switch rec.Frequency {
+	case FrequencyDaily:
+		next = from.AddDate(0, 0, interval)
+	case FrequencyWeekly:
+		next = rec.nextWeekly(from, interval)
+	case FrequencyMonthly:
+		next = addMonthsClamped(from, interval)
+	default:
+		return time.Time{}, false
+	}
+
+	if rec.Until != nil && next.After(*rec.Until) {
+		return time.Time{}, false
+	}
+
+	return next, true
+}
+
+// nextWeekly finds the next selected weekday after from, moving on to the week
+// interval weeks later once the current week's days are used up.
+func (rec Recurrence) nextWeekly(from time.Time, interval int) time.Time {
+	if len(rec.ByWeekday) == 0 {
+		return from.AddDate(0, 0, 7*interval)
+	}
+
+	selected := map[time.Weekday]bool{}
+	for _, code := range rec.ByWeekday {
+		selected[weekdayCodes[code]] = true
+	}
+
+	// Days are counted from Monday so weeks follow the RRULE default of WKST=MO
+	offset := (int(from.Weekday()) + 6) % 7
+	for day := offset + 1; day < 7; day++ {
+		if selected[time.Weekday((day+1)%7)] {
+			return from.AddDate(0, 0, day-offset)
+		}
+	}
+
+	weekStart := from.AddDate(0, 0, 7*interval-offset)
+	for day := 0; day < 7; day++ {
+		if selected[time.Weekday((day+1)%7)] {
+			return weekStart.AddDate(0, 0, day)
+		}
+	}
+
+	return weekStart
+}
+
+// addMonthsClamped adds months to t, clamping the day to the end of shorter months.
+func addMonthsClamped(t time.Time, months int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
+
+// Value stores the recurrence as JSON. It is sent as a string because byte
+// slices are encoded as bytea, which cannot be cast to jsonb.
+func (rec Recurrence) Value() (driver.Value, error) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+// Scan reads a recurrence stored as JSON.
+func (rec *Recurrence) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, rec)
+	case string:
+		return json.Unmarshal([]byte(v), rec)
+	default:
+		return fmt.Errorf("cannot scan %T into Recurrence", src)
+	}
+}
+
+// SetRecurrence changes how a todo repeats and optionally its due date, on
+// behalf of a user. Making a todo recurring starts a series at it; a nil rec
+// stops the series at the current occurrence while keeping the link to
+// earlier ones.
+func (ts *TodoStore) SetRecurrence(todoID int, rec *Recurrence, dueAt *time.Time, userID int) (*Todo, error) {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	todo, err := ts.changeTodo(tx, todoID, func(todo *Todo) error {
+		todo.Recurrence = rec
+		if dueAt != nil {
+			todo.DueAt = dueAt
+		}
+		return nil
+	}, userID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ts.loadTodo(todo, nil)
+}
+
+// GetSeries retrieves every occurrence of a recurring todo series in order.
+func (ts *TodoStore) GetSeries(seriesID int) ([]Todo, error) {
+	return ts.listTodos([]string{"series_id = $1"}, []interface{}{seriesID}, TodoFilter{})
+}
+
+// createNextOccurrence creates the occurrence following a completed recurring
+// todo, carrying over its details and tags. Nothing is created when the series
+// has ended or the next occurrence already exists.
+func createNextOccurrence(q queryer, todo *Todo) error {
+	seriesID, occurrence := todo.ID, 1
+	if todo.SeriesID != nil {
+		seriesID = *todo.SeriesID
+	}
+	if todo.Occurrence != nil {
+		occurrence = *todo.Occurrence
+	}
+
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM todos WHERE series_id = $1 AND occurrence > $2)"
+	if err := q.QueryRow(query, seriesID, occurrence).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	from := time.Now()
+	if todo.DueAt != nil {
+		from = *todo.DueAt
+	}
+
+	dueAt, ok := todo.Recurrence.Next(from, occurrence)
+	if !ok {
+		return nil
+	}
+
+	var nextID int
+	query = `INSERT INTO todos(title, description, status, user_id, workspace_id, list_id, parent_id, due_at, recurrence, series_id, occurrence)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
+	err := q.QueryRow(query, todo.Title, todo.Description, TodoStatusActive, todo.UserID, todo.WorkspaceID, todo.ListID, todo.ParentID,
+		dueAt, todo.Recurrence, seriesID, occurrence+1).Scan(&nextID)
+	if err != nil {
+		return err
+	}
+
+	query = "INSERT INTO todo_tags(todo_id, tag_id) SELECT $1, tag_id FROM todo_tags WHERE todo_id = $2"
+	_, err = q.Exec(query, nextID, todo.ID)
+	return err
+}
@@ -0,0 +1,293 @@
+package models
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TodoSnapshot holds the fields of a todo that are kept in its revision
+// history. Revisions recorded before DueAt, ListID, ParentID and Recurrence
+// were kept lack those members and leave them unchanged when reverted to.
+type TodoSnapshot struct {
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Status      string      `json:"status"`
+	DueAt       *time.Time  `json:"due_at"`
+	ListID      *int        `json:"list_id"`
+	ParentID    *int        `json:"parent_id"`
+	Recurrence  *Recurrence `json:"recurrence"`
+}
+
+// FieldChange is the value of a field before and after a revision, in the
+// field's JSON representation; null stands for a cleared field.
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// TodoRevision is one entry in the history of a todo: the changed fields, who
+// changed them and the state of the todo afterwards. The first revision of a
+// todo records its state before it was first changed and has no changes.
+type TodoRevision struct {
+	ID           int                    `json:"id"`
+	TodoID       int                    `json:"todo_id"`
+	Revision     int                    `json:"revision"`
+	UserID       *int                   `json:"user_id"`
+	Actor        *string                `json:"actor"`
+	Snapshot     TodoSnapshot           `json:"snapshot"`
+	Changes      map[string]FieldChange `json:"changes"`
+	RevertedFrom *int                   `json:"reverted_from,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// snapshotOf returns the revisioned fields of a todo.
+func snapshotOf(todo *Todo) TodoSnapshot {
+	return TodoSnapshot{
+		Title:       todo.Title,
+		Description: todo.Description,
+		Status:      todo.Status,
+		DueAt:       todo.DueAt,
+		ListID:      todo.ListID,
+		ParentID:    todo.ParentID,
+		Recurrence:  todo.Recurrence,
+	}
+}
+
+// diffSnapshots returns the fields that differ between two snapshots.
+func diffSnapshots(before, after TodoSnapshot) map[string]FieldChange {
+	changes := map[string]FieldChange{}
+	if before.Title != after.Title {
+		changes["title"] = FieldChange{From: before.Title, To: after.Title}
+	}
+	if before.Description != after.Description {
+		changes["description"] = FieldChange{From: before.Description, To: after.Description}
+	}
+	if before.Status != after.Status {
+		changes["status"] = FieldChange{From: before.Status, To: after.Status}
+	}
+	if !equalTimes(before.DueAt, after.DueAt) {
+		changes["due_at"] = FieldChange{From: before.DueAt, To: after.DueAt}
+	}
+	if !equalInts(before.ListID, after.ListID) {
+		changes["list_id"] = FieldChange{From: before.ListID, To: after.ListID}
+	}
+	if !equalInts(before.ParentID, after.ParentID) {
+		changes["parent_id"] = FieldChange{From: before.ParentID, To: after.ParentID}
+	}
+	if !equalJSON(before.Recurrence, after.Recurrence) {
+		changes["recurrence"] = FieldChange{From: before.Recurrence, To: after.Recurrence}
+	}
+
+	return changes
+}
+
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func equalInts(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// equalJSON reports whether a and b have the same JSON representation.
+func equalJSON(a, b interface{}) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aJSON, bJSON)
+}
+
+// Value stores the snapshot as JSON, sent as a string for the same reason as Recurrence.
+func (s TodoSnapshot) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+// Scan reads a snapshot stored as JSON.
+func (s *TodoSnapshot) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("cannot scan %T into TodoSnapshot", src)
+	}
+}
+
+// recordRevision appends a revision for a change from before to after, made by
+// actorID, to the history of the todo. Changes that leave the revisioned fields
+// as they were are not recorded unless they revert to an earlier revision. The
+// first recorded change is preceded by a revision holding the original state.
+func recordRevision(q queryer, before, after *Todo, actorID int, revertedFrom *int) error {
+	changes := diffSnapshots(snapshotOf(before), snapshotOf(after))
+	if len(changes) == 0 && revertedFrom == nil {
+		return nil
+	}
+
+	var latest int
+	err := q.QueryRow("SELECT COALESCE(MAX(revision), 0) FROM todo_revisions WHERE todo_id = $1", after.ID).Scan(&latest)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO todo_revisions(todo_id, revision, user_id, snapshot, changes, reverted_from)
+		VALUES($1, $2, $3, $4, $5, $6)`
+	if latest == 0 {
+		latest++
+		if _, err := q.Exec(query, after.ID, latest, nil, snapshotOf(before), "{}", nil); err != nil {
+			return err
+		}
+	}
+
+	b, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(query, after.ID, latest+1, actorID, snapshotOf(after), string(b), revertedFrom)
+	return err
+}
+
+const revisionQuery = `SELECT r.id, r.todo_id, r.revision, r.user_id, u.username, r.snapshot, r.changes, r.reverted_from, r.created_at
+	FROM todo_revisions r LEFT JOIN users u ON u.id = r.user_id`
+
+func scanRevision(row rowScanner) (*TodoRevision, error) {
+	var revision TodoRevision
+	var changes []byte
+	err := row.Scan(&revision.ID, &revision.TodoID, &revision.Revision, &revision.UserID, &revision.Actor,
+		&revision.Snapshot, &changes, &revision.RevertedFrom, &revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(changes, &revision.Changes); err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+// GetTodoHistory retrieves a page of the revisions of a todo, newest first.
+func (ts *TodoStore) GetTodoHistory(todoID, limit, offset int) ([]TodoRevision, error) {
+	revisions := []TodoRevision{}
+	query := revisionQuery + " WHERE r.todo_id = $1 ORDER BY r.revision DESC LIMIT $2 OFFSET $3"
+	rows, err := ts.DB.Query(query, todoID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+// GetTodoRevision retrieves one revision of a todo, showing the todo as it was at that point.
+func (ts *TodoStore) GetTodoRevision(todoID, revision int) (*TodoRevision, error) {
+	query := revisionQuery + " WHERE r.todo_id = $1 AND r.revision = $2"
+	return scanRevision(ts.DB.QueryRow(query, todoID, revision))
+}
+
+// RevertTodo restores the revisioned fields of a todo to how they were at an
+// earlier revision. The revert is itself recorded as a new revision, and goes
+// through the same checks and follow-ups as any other change. It returns
+// sql.ErrNoRows if the revision does not exist. A list that has been deleted
+// since is not restored, and restoring a list of another user fails with
+// ErrListNotOwned.
+func (ts *TodoStore) RevertTodo(todoID, revision, userID int) (*Todo, error) {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var raw []byte
+	query := "SELECT snapshot FROM todo_revisions WHERE todo_id = $1 AND revision = $2"
+	if err := tx.QueryRow(query, todoID, revision).Scan(&raw); err != nil {
+		return nil, err
+	}
+
+	// Older snapshots do not hold every field; those they lack are kept
+	var snapshot TodoSnapshot
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return nil, err
+	}
+
+	todo, err := ts.changeTodo(tx, todoID, func(todo *Todo) error {
+		todo.Title, todo.Description, todo.Status = snapshot.Title, snapshot.Description, snapshot.Status
+		if _, ok := members["due_at"]; ok {
+			todo.DueAt = snapshot.DueAt
+		}
+		if _, ok := members["list_id"]; ok {
+			listID, err := revertedListID(tx, todo.ListID, snapshot.ListID, userID)
+			if err != nil {
+				return err
+			}
+			todo.ListID = listID
+		}
+		if _, ok := members["parent_id"]; ok {
+			todo.ParentID = snapshot.ParentID
+		}
+		if _, ok := members["recurrence"]; ok {
+			todo.Recurrence = snapshot.Recurrence
+		}
+		return nil
+	}, userID, nil, &revision)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ts.loadTodo(todo, nil)
+}
+
+// revertedListID returns the list a revert moves a todo from current to,
+// checking that a list other than the current one still exists and belongs
+// to userID, like the lists todos are placed in by other changes.
+func revertedListID(q queryer, current, listID *int, userID int) (*int, error) {
+	if listID == nil || (current != nil && *current == *listID) {
+		return listID, nil
+	}
+
+	var owner int
+	err := q.QueryRow("SELECT user_id FROM lists WHERE id = $1 FOR SHARE", *listID).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if owner != userID {
+		return nil, ErrListNotOwned
+	}
+
+	return listID, nil
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// ErrEmptySearch is returned when a search query contains no searchable terms.
+var ErrEmptySearch = errors.New("search query has no searchable terms")
+
+// accessibleTodoCondition matches todos t the user $1 can read: their personal
+// todos, the todos of their workspaces and the todos shared with them.
+const accessibleTodoCondition = `((t.user_id = $1 AND t.workspace_id IS NULL)
+	OR t.workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = $1)
+	OR t.id IN (SELECT todo_id FROM todo_shares WHERE user_id = $1))`
+
+// Markers placed around matches by ts_headline. They are replaced with <mark>
+// tags once the rest of the text has been HTML escaped.
+const (
+	highlightStart = "\x1e"
+	highlightStop  = "\x1f"
+)
+
+// SearchResult is a todo matching a search, with its rank and the matching
+// text. Highlights are HTML with matches wrapped in <mark> tags.
+type SearchResult struct {
+	Todo
+	Rank                 float64 `json:"rank"`
+	Highlight            string  `json:"highlight"`
+	DescriptionHighlight string  `json:"description_highlight,omitempty"`
+	CommentHighlight     string  `json:"comment_highlight,omitempty"`
+}
+
+// searchTerm is one part of a search query.
+type searchTerm struct {
+	text   string
+	phrase bool
+	prefix bool
+	negate bool
+}
+
+// parseSearchQuery splits a search query into terms. Words must all match,
+// "quoted words" must match as a phrase, a trailing * matches words starting
+// with the term and a leading - excludes todos matching the term.
+func parseSearchQuery(q string) []searchTerm {
+	var terms []searchTerm
+	runes := []rune(q)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		var term searchTerm
+		if runes[i] == '-' {
+			term.negate = true
+			i++
+		}
+
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			term.text, term.phrase = string(runes[i+1:end]), true
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			term.text = string(runes[i:end])
+			i = end
+		}
+
+		if !term.phrase && strings.HasSuffix(term.text, "*") {
+			// Prefix terms go through to_tsquery, so keep only word characters
+			term.prefix = true
+			term.text = strings.Map(func(r rune) rune {
+				if unicode.IsLetter(r) || unicode.IsDigit(r) {
+					return r
+				}
+				return -1
+			}, term.text)
+		}
+
+		if strings.TrimSpace(term.text) != "" {
+			terms = append(terms, term)
+		}
+	}
+
+	return terms
+}
+
+// tsQuery builds the SQL tsquery expression for terms, appending their text to args.
+func tsQuery(terms []searchTerm, args *[]interface{}) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		function, text := "plainto_tsquery", term.text
+		if term.phrase {
+			function = "phraseto_tsquery"
+		} else if term.prefix {
+			function, text = "to_tsquery", text+":*"
+		}
+
+		*args = append(*args, text)
+		parts[i] = fmt.Sprintf("%s('english', $%d)", function, len(*args))
+		if term.negate {
+			parts[i] = "!!" + parts[i]
+		}
+	}
+
+	return strings.Join(parts, " && ")
+}
+
+// formatHighlight HTML escapes text produced by ts_headline and marks its matches.
+func formatHighlight(text string) string {
+	text = html.EscapeString(text)
+	text = strings.ReplaceAll(text, highlightStart, "<mark>")
+	return strings.ReplaceAll(text, highlightStop, "</mark>")
+}
+
+// SearchTodos finds the todos readable by a user whose title, description or comments
+// match a search query, best matches first.
+func (ts *TodoStore) SearchTodos(userID int, q string, limit, offset int) ([]SearchResult, error) {
+	terms := parseSearchQuery(q)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+
+	args := []interface{}{userID, "StartSel=" + highlightStart + ", StopSel=" + highlightStop}
+	query := `WITH search AS (SELECT ` + tsQuery(terms, &args) + ` AS query)
+		SELECT ` + todoColumns + `, rank, highlight, description_highlight, comment_highlight FROM (
+			SELECT t.*,
+				ts_rank(t.search_vector, search.query) + COALESCE(c.rank, 0) AS rank,
+				ts_headline('english', t.title, search.query, $2::text || ', HighlightAll=true') AS highlight,
+				CASE WHEN to_tsvector('english', t.description) @@ search.query
+					THEN ts_headline('english', t.description, search.query, $2::text || ', MaxFragments=2') ELSE '' END AS description_highlight,
+				COALESCE(ts_headline('english', c.body, search.query, $2::text || ', MaxFragments=2'), '') AS comment_highlight
+			FROM todos t CROSS JOIN search
+			LEFT JOIN LATERAL (
+				SELECT body, ts_rank(search_vector, search.query) AS rank FROM todo_comments
+				WHERE todo_id = t.id AND deleted_at IS NULL AND search_vector @@ search.query
+				ORDER BY rank DESC LIMIT 1
+			) c ON true
+			WHERE ` + accessibleTodoCondition + ` AND t.deleted_at IS NULL
+			AND (t.search_vector @@ search.query OR c.body IS NOT NULL)
+		) results`
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(" ORDER BY rank DESC, id LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := ts.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todos []Todo
+	var results []SearchResult
+	for rows.Next() {
+		var result SearchResult
+		todo, err := scanTodo(rows, &result.Rank, &result.Highlight, &result.DescriptionHighlight, &result.CommentHighlight)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, *todo)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := ts.loadTodoDetails(todos); err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].Todo = todos[i]
+		results[i].Highlight = formatHighlight(results[i].Highlight)
+		results[i].DescriptionHighlight = formatHighlight(results[i].DescriptionHighlight)
+		results[i].CommentHighlight = formatHighlight(results[i].CommentHighlight)
+	}
+	if results == nil {
+		results = []SearchResult{}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// MaxSubtaskDepth is the number of levels a todo tree may have, counting the top-level todo.
+const MaxSubtaskDepth = 5
+
+var (
+	// ErrSubtaskCycle is returned when a todo would become a subtask of itself or of one of its subtasks.
+	ErrSubtaskCycle = errors.New("a todo cannot be a subtask of itself or of its own subtasks")
+	// ErrSubtaskDepth is returned when a todo tree would grow deeper than MaxSubtaskDepth.
+	ErrSubtaskDepth = errors.New("subtasks cannot be nested that deeply")
+	// ErrParentNotFound is returned when the parent of a subtask does not exist.
+	ErrParentNotFound = errors.New("parent todo not found")
+)
+
+// GetSubtasks retrieves the direct subtasks of a todo.
+func (ts *TodoStore) GetSubtasks(parentID int) ([]Todo, error) {
+	return ts.listTodos([]string{"parent_id = $1"}, []interface{}{parentID}, TodoFilter{})
+}
+
+// SetTodoParent makes a todo a subtask of another todo on behalf of a user. A
+// nil parentID turns it back into a top-level todo.
+func (ts *TodoStore) SetTodoParent(todoID int, parentID *int, userID int) (*Todo, error) {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	todo, err := ts.changeTodo(tx, todoID, func(todo *Todo) error {
+		todo.ParentID = parentID
+		return nil
+	}, userID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ts.loadTodo(todo, nil)
+}
+
+// validateParent checks that todoID (0 for a todo that does not exist yet) can
+// be placed under parentID without creating a cycle or exceeding MaxSubtaskDepth.
+func validateParent(q queryer, todoID, parentID int) error {
+	if todoID == parentID {
+		return ErrSubtaskCycle
+	}
+
+	// Lock the parent's tree against concurrent reparenting
+	if _, err := q.Exec("SELECT pg_advisory_xact_lock(user_id) FROM todos WHERE id = $1", parentID); err != nil {
+		return err
+	}
+
+	// Walk up from the new parent, looking for the todo being moved
+	var parentDepth, cycles int
+	query := `WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 1 AS depth FROM todos WHERE id = $1 AND deleted_at IS NULL
+			UNION ALL
+			SELECT t.id, t.parent_id, a.depth + 1 FROM todos t
+			JOIN ancestors a ON t.id = a.parent_id WHERE a.depth <= $2
+		)
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE id = $3) FROM ancestors`
+	if err := q.QueryRow(query, parentID, MaxSubtaskDepth, todoID).Scan(&parentDepth, &cycles); err != nil {
+		return err
+	}
+	if parentDepth == 0 {
+		return ErrParentNotFound
+	}
+	if cycles > 0 {
+		return ErrSubtaskCycle
+	}
+
+	// Measure how many levels the todo being moved brings along
+	height := 1
+	if todoID != 0 {
+		query := `WITH RECURSIVE subtree AS (
+				SELECT id, 1 AS depth FROM todos WHERE id = $1
+				UNION ALL
+				SELECT t.id, s.depth + 1 FROM todos t
+				JOIN subtree s ON t.parent_id = s.id WHERE s.depth <= $2
+			)
+			SELECT COALESCE(MAX(depth), 1) FROM subtree`
+		if err := q.QueryRow(query, todoID, MaxSubtaskDepth).Scan(&height); err != nil {
+			return err
+		}
+	}
+
+	if parentDepth+height > MaxSubtaskDepth {
+		return ErrSubtaskDepth
+	}
+
+	return nil
+}
+
+// completeParent marks parentID as done on behalf of userID if all of its
+// subtasks are done. The change goes through changeTodo like any other, so it
+// is recorded in the parent's history and its own follow-ups continue up the
+// tree; a parent that is blocked or whose board column is full is left open.
+func (ts *TodoStore) completeParent(q queryer, parentID, userID int) error {
+	var complete bool
+	query := `SELECT status <> $2 AND NOT EXISTS (SELECT 1 FROM todos WHERE parent_id = $1 AND status <> $2 AND deleted_at IS NULL)
+		FROM todos WHERE id = $1 AND deleted_at IS NULL`
+	err := q.QueryRow(query, parentID, TodoStatusDone).Scan(&complete)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !complete) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = ts.changeTodo(q, parentID, func(todo *Todo) error {
+		todo.Status = TodoStatusDone
+		return nil
+	}, userID, nil, nil)
+	if errors.Is(err, ErrTodoBlocked) || errors.Is(err, ErrWIPLimitReached) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// Tag represents a label a user can attach to todos.
+type Tag struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+	Color  string `json:"color"`
+}
+
+// TagStore is responsible for interacting with the tag data in the database.
+type TagStore struct {
+	DB *sql.DB
+}
+
+// NewTagStore creates a new TagStore instance.
+func NewTagStore(db *sql.DB) *TagStore {
+	return &TagStore{DB: db}
+}
+
+// GetTagsByUserID retrieves a user's tags ordered by name.
+func (ts *TagStore) GetTagsByUserID(userID int) ([]Tag, error) {
+	tags := []Tag{}
+	query := "SELECT id, user_id, name, color FROM tags WHERE user_id = $1 ORDER BY name"
+	rows, err := ts.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.Color); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetTagByID retrieves a tag by its ID.
+func (ts *TagStore) GetTagByID(tagID int) (*Tag, error) {
+	var tag Tag
+	query := "SELECT id, user_id, name, color FROM tags WHERE id = $1"
+	err := ts.DB.QueryRow(query, tagID).Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.Color)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+// CreateTag creates a new tag for a user. Creating a tag with a name the user
+// already has returns the existing tag with its color updated.
+func (ts *TagStore) CreateTag(userID int, name, color string) (*Tag, error) {
+	tag := Tag{UserID: userID, Name: name, Color: color}
+	query := `INSERT INTO tags(user_id, name, color) VALUES($1, $2, $3)
+		ON CONFLICT (user_id, name) DO UPDATE SET color = EXCLUDED.color RETURNING id`
+	err := ts.DB.QueryRow(query, userID, name, color).Scan(&tag.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+// DeleteTag deletes a tag and detaches it from every todo.
+func (ts *TagStore) DeleteTag(tagID int) error {
+	query := "DELETE FROM tags WHERE id = $1"
+	_, err := ts.DB.Exec(query, tagID)
+	return err
+}
+
+// AttachTag attaches a tag to a todo. Attaching an already attached tag is a no-op.
+func (ts *TagStore) AttachTag(todoID, tagID int) error {
+	query := "INSERT INTO todo_tags(todo_id, tag_id) VALUES($1, $2) ON CONFLICT DO NOTHING"
+	_, err := ts.DB.Exec(query, todoID, tagID)
+	return err
+}
+
+// DetachTag removes a tag from a todo.
+func (ts *TagStore) DetachTag(todoID, tagID int) error {
+	query := "DELETE FROM todo_tags WHERE todo_id = $1 AND tag_id = $2"
+	_, err := ts.DB.Exec(query, todoID, tagID)
+	return err
+}
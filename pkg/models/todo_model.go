@@ -2,103 +2,686 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Access levels a user can hold on a todo, from least to most privileged.
+const (
+	TodoAccessNone  = ""
+	TodoAccessRead  = "read"
+	TodoAccessEdit  = "edit"
+	TodoAccessOwner = "owner"
+)
+
+var todoAccessRank = map[string]int{
+	TodoAccessNone:  0,
+	TodoAccessRead:  1,
+	TodoAccessEdit:  2,
+	TodoAccessOwner: 3,
+}
+
+// TodoAccessAtLeast reports whether access grants at least the privileges of minAccess.
+func TodoAccessAtLeast(access, minAccess string) bool {
+	return todoAccessRank[access] >= todoAccessRank[minAccess]
+}
+
+// Todo statuses with a meaning to the system. Other status values are stored as given.
+const (
+	TodoStatusActive     = "active"
+	TodoStatusInProgress = "in_progress"
+	TodoStatusDone       = "done"
 )
 
+// MaxDescriptionLength is the longest todo description accepted, in characters.
+const MaxDescriptionLength = 20000
+
+// ErrVersionMismatch is returned when a todo is changed on the condition that
+// it is at a version it is no longer at.
+var ErrVersionMismatch = errors.New("the todo has been modified since it was retrieved")
+
 // Todo represents a task in the system.
 type Todo struct {
-	ID     int    `json:"id"`
-	Title  string `json:"title"`
-	Status string `json:"status"`
-	UserID int    `json:"user_id"`
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// DescriptionHTML is the description rendered from markdown. It is only
+	// filled in when a client asks for it.
+	DescriptionHTML string      `json:"description_html,omitempty"`
+	Status          string      `json:"status"`
+	UserID          int         `json:"user_id"`
+	WorkspaceID     *int        `json:"workspace_id,omitempty"`
+	ListID          *int        `json:"list_id,omitempty"`
+	ParentID        *int        `json:"parent_id,omitempty"`
+	DueAt           *time.Time  `json:"due_at,omitempty"`
+	Recurrence      *Recurrence `json:"recurrence,omitempty"`
+	// SeriesID is the ID of the first occurrence of a recurring todo, shared by
+	// every occurrence generated from it; Occurrence numbers them from 1.
+	SeriesID   *int `json:"series_id,omitempty"`
+	Occurrence *int `json:"occurrence,omitempty"`
+	// Position orders todos manually; listings are sorted by it.
+	Position float64 `json:"position"`
+	// DeletedAt and DeletedBy are set on todos in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy *int       `json:"deleted_by,omitempty"`
+	// Version is incremented by every change to the todo other than a change
+	// of Position. ETag is filled in when the todo is sent to a client and is
+	// used in If-Match headers.
+	Version int    `json:"version"`
+	ETag    string `json:"etag,omitempty"`
+	Tags    []Tag  `json:"tags"`
+	// BlockedBy lists the todos this todo depends on and Blocks the todos
+	// depending on it. Blocked is set while any todo in BlockedBy is not done.
+	BlockedBy []int `json:"blocked_by"`
+	Blocks    []int `json:"blocks"`
+	Blocked   bool  `json:"blocked"`
+	// Progress is the fraction of direct subtasks that are done. It is only
+	// set on todos that have subtasks.
+	Progress *float64 `json:"progress,omitempty"`
+	// Shared is set on todos listed by GetTodosByUserID because they were
+	// shared with the user rather than being their personal todos.
+	Shared bool `json:"shared,omitempty"`
+}
+
+// Tag matching modes for TodoFilter.
+const (
+	TagModeAny = "any"
+	TagModeAll = "all"
+)
+
+// Modes of TodoFilter.Shared.
+const (
+	SharedExclude = ""
+	SharedInclude = "include"
+	SharedOnly    = "only"
+)
+
+// TodoFilter narrows down a todo listing. Zero values apply no restriction.
+type TodoFilter struct {
+	Status string
+	// Shared adds the todos shared with the user to their personal todos
+	// (SharedInclude) or lists only those (SharedOnly). Only GetTodosByUserID
+	// uses it.
+	Shared string
+	// Tags restricts the listing to todos carrying any (or, with TagMode
+	// TagModeAll, every one) of the named tags.
+	Tags    []string
+	TagMode string
+	Limit   int
+	Offset  int
 }
 
 // TodoStore is responsible for interacting with the todo data in the database.
 type TodoStore struct {
 	DB *sql.DB
+	// AutoCompleteParents marks a todo as done once all of its subtasks are done.
+	AutoCompleteParents bool
 }
 
 // NewTodoStore creates a new TodoStore instance.
 func NewTodoStore(db *sql.DB) *TodoStore {
-	return &TodoStore{DB: db}
+	return &TodoStore{DB: db, AutoCompleteParents: true}
+}
+
+// todoColumns is the column list scanned by scanTodo.
+const todoColumns = "id, title, description, status, user_id, workspace_id, list_id, parent_id, due_at, recurrence, series_id, occurrence, position, deleted_at, deleted_by, version"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
-// GetTodosByUserID retrieves all todos for a given user ID.
-func (ts *TodoStore) GetTodosByUserID(userID int) ([]Todo, error) {
-	var todos []Todo
-	query := "SELECT id, title, status FROM todos WHERE user_id = $1"
-	rows, err := ts.DB.Query(query, userID)
+// scanTodo scans a row selecting todoColumns, followed by any extra columns into extra.
+func scanTodo(row rowScanner, extra ...interface{}) (*Todo, error) {
+	var todo Todo
+	dest := []interface{}{
+		&todo.ID, &todo.Title, &todo.Description, &todo.Status, &todo.UserID, &todo.WorkspaceID, &todo.ListID, &todo.ParentID,
+		&todo.DueAt, &todo.Recurrence, &todo.SeriesID, &todo.Occurrence, &todo.Position, &todo.DeletedAt, &todo.DeletedBy,
+		&todo.Version,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &todo, nil
+}
+
+// queryTodos runs a query selecting todoColumns and collects the resulting todos.
+func (ts *TodoStore) queryTodos(query string, args ...interface{}) ([]Todo, error) {
+	todos := []Todo{}
+	rows, err := ts.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var todo Todo
-		if err := rows.Scan(&todo.ID, &todo.Title, &todo.Status); err != nil {
+		todo, err := scanTodo(rows)
+		if err != nil {
 			return nil, err
 		}
-		todo.UserID = userID
-		todos = append(todos, todo)
+		todos = append(todos, *todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := ts.loadTodoDetails(todos); err != nil {
+		return nil, err
 	}
 
 	return todos, nil
 }
 
-// GetTodoByID retrieves a todo by its ID.
+// loadTodo fills in the related data of a single todo returned by a store method.
+func (ts *TodoStore) loadTodo(todo *Todo, err error) (*Todo, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	todos := []Todo{*todo}
+	if err := ts.loadTodoDetails(todos); err != nil {
+		return nil, err
+	}
+
+	return &todos[0], nil
+}
+
+// loadTodoDetails fills in the related data of todos, issuing one query per
+// relation for the whole slice rather than one per todo.
+func (ts *TodoStore) loadTodoDetails(todos []Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(todos))
+	index := make(map[int]int, len(todos))
+	for i := range todos {
+		ids[i] = int64(todos[i].ID)
+		index[todos[i].ID] = i
+		todos[i].Tags = []Tag{}
+		todos[i].BlockedBy = []int{}
+		todos[i].Blocks = []int{}
+	}
+
+	query := `SELECT tt.todo_id, t.id, t.user_id, t.name, t.color FROM todo_tags tt
+		JOIN tags t ON t.id = tt.tag_id
+		WHERE tt.todo_id = ANY($1) ORDER BY t.name`
+	rows, err := ts.DB.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoID int
+		var tag Tag
+		if err := rows.Scan(&todoID, &tag.ID, &tag.UserID, &tag.Name, &tag.Color); err != nil {
+			return err
+		}
+		i := index[todoID]
+		todos[i].Tags = append(todos[i].Tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = `SELECT parent_id, COUNT(*), COUNT(*) FILTER (WHERE status = $2) FROM todos
+		WHERE parent_id = ANY($1) AND deleted_at IS NULL GROUP BY parent_id`
+	rows, err = ts.DB.Query(query, pq.Array(ids), TodoStatusDone)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoID, total, done int
+		if err := rows.Scan(&todoID, &total, &done); err != nil {
+			return err
+		}
+		progress := float64(done) / float64(total)
+		todos[index[todoID]].Progress = &progress
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	query = `SELECT d.todo_id, d.depends_on_id, t.status FROM todo_dependencies d
+		JOIN todos t ON t.id = d.depends_on_id
+		JOIN todos dependent ON dependent.id = d.todo_id
+		WHERE (d.todo_id = ANY($1) OR d.depends_on_id = ANY($1))
+		AND t.deleted_at IS NULL AND dependent.deleted_at IS NULL
+		ORDER BY d.todo_id, d.depends_on_id`
+	rows, err = ts.DB.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoID, dependsOnID int
+		var dependsOnStatus string
+		if err := rows.Scan(&todoID, &dependsOnID, &dependsOnStatus); err != nil {
+			return err
+		}
+		if i, ok := index[todoID]; ok {
+			todos[i].BlockedBy = append(todos[i].BlockedBy, dependsOnID)
+			if dependsOnStatus != TodoStatusDone {
+				todos[i].Blocked = true
+			}
+		}
+		if i, ok := index[dependsOnID]; ok {
+			todos[i].Blocks = append(todos[i].Blocks, todoID)
+		}
+	}
+
+	return rows.Err()
+}
+
+// listTodos retrieves the todos matching every condition and the filter,
+// leaving out todos in the trash. Conditions reference args by position, and
+// filter arguments are appended after them.
+func (ts *TodoStore) listTodos(conditions []string, args []interface{}, filter TodoFilter) ([]Todo, error) {
+	conditions = append(conditions, "deleted_at IS NULL")
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	if len(filter.Tags) > 0 {
+		args = append(args, pq.Array(filter.Tags))
+		condition := fmt.Sprintf(`id IN (SELECT tt.todo_id FROM todo_tags tt
+			JOIN tags t ON t.id = tt.tag_id WHERE t.name = ANY($%d)`, len(args))
+		if filter.TagMode == TagModeAll {
+			args = append(args, len(filter.Tags))
+			condition += fmt.Sprintf(" GROUP BY tt.todo_id HAVING COUNT(DISTINCT t.name) = $%d", len(args))
+		}
+		conditions = append(conditions, condition+")")
+	}
+
+	query := "SELECT " + todoColumns + " FROM todos WHERE " + strings.Join(conditions, " AND ") + " ORDER BY position, id"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	return ts.queryTodos(query, args...)
+}
+
+// GetTodosByUserID retrieves the personal todos (those outside any workspace)
+// for a given user ID, along with or instead of the todos shared with them as
+// set by filter.Shared.
+func (ts *TodoStore) GetTodosByUserID(userID int, filter TodoFilter) ([]Todo, error) {
+	condition := "(user_id = $1 AND workspace_id IS NULL)"
+	shared := "id IN (SELECT todo_id FROM todo_shares WHERE user_id = $1)"
+	switch filter.Shared {
+	case SharedInclude:
+		condition = "(" + condition + " OR " + shared + ")"
+	case SharedOnly:
+		condition = shared + " AND NOT " + condition
+	}
+
+	todos, err := ts.listTodos([]string{condition}, []interface{}{userID}, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range todos {
+		todos[i].Shared = todos[i].UserID != userID || todos[i].WorkspaceID != nil
+	}
+
+	return todos, nil
+}
+
+// GetTodosByWorkspaceID retrieves the todos in a workspace.
+func (ts *TodoStore) GetTodosByWorkspaceID(workspaceID int, filter TodoFilter) ([]Todo, error) {
+	return ts.listTodos([]string{"workspace_id = $1"}, []interface{}{workspaceID}, filter)
+}
+
+// GetTodosByListID retrieves the todos in a list.
+func (ts *TodoStore) GetTodosByListID(listID int, filter TodoFilter) ([]Todo, error) {
+	return ts.listTodos([]string{"list_id = $1"}, []interface{}{listID}, filter)
+}
+
+// GetTodoByID retrieves a todo by its ID. Todos in the trash are not found.
 func (ts *TodoStore) GetTodoByID(todoID int) (*Todo, error) {
-	var todo Todo
-	query := "SELECT id, title, status, user_id FROM todos WHERE id = $1"
-	err := ts.DB.QueryRow(query, todoID).Scan(&todo.ID, &todo.Title, &todo.Status, &todo.UserID)
+	query := "SELECT " + todoColumns + " FROM todos WHERE id = $1 AND deleted_at IS NULL"
+	return ts.loadTodo(scanTodo(ts.DB.QueryRow(query, todoID)))
+}
+
+// GetAccessLevel determines the access a user holds on a todo. Personal todos
+// are accessible to their creator, workspace todos follow the user's role in
+// the workspace, and an individual share can raise either to read or edit.
+func (ts *TodoStore) GetAccessLevel(todo *Todo, userID int) (string, error) {
+	access := TodoAccessNone
+
+	if todo.WorkspaceID == nil {
+		if todo.UserID == userID {
+			return TodoAccessOwner, nil
+		}
+	} else {
+		var role string
+		query := "SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2"
+		err := ts.DB.QueryRow(query, *todo.WorkspaceID, userID).Scan(&role)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return TodoAccessNone, err
+		}
+
+		switch role {
+		case WorkspaceRoleOwner:
+			return TodoAccessOwner, nil
+		case WorkspaceRoleEditor:
+			access = TodoAccessEdit
+		case WorkspaceRoleViewer:
+			access = TodoAccessRead
+		}
+	}
+
+	var level string
+	query := "SELECT level FROM todo_shares WHERE todo_id = $1 AND user_id = $2"
+	err := ts.DB.QueryRow(query, todo.ID, userID).Scan(&level)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return TodoAccessNone, err
+	}
+
+	if TodoAccessAtLeast(level, access) {
+		access = level
+	}
+
+	return access, nil
+}
+
+// CreateTodo creates a new todo in the database from the title, description, status, owner,
+// workspace, list, parent, due date and recurrence of the given todo. A
+// recurring todo starts a new series.
+func (ts *TodoStore) CreateTodo(todo Todo) (*Todo, error) {
+	tx, err := ts.DB.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	return &todo, nil
+	createdTodo, err := createTodo(tx, todo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ts.loadTodo(createdTodo, nil)
 }
 
-// CreateTodo creates a new todo in the database.
-func (ts *TodoStore) CreateTodo(userID int, title, status string) (*Todo, error) {
-	var todoID int
-	query := "INSERT INTO todos(title, status, user_id) VALUES($1, $2, $3) RETURNING id"
-	err := ts.DB.QueryRow(query, title, status, userID).Scan(&todoID)
+// createTodo creates a todo as part of a transaction.
+func createTodo(q queryer, todo Todo) (*Todo, error) {
+	if todo.ParentID != nil {
+		if err := validateParent(q, 0, *todo.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	query := "INSERT INTO todos(title, description, status, user_id, workspace_id, list_id, parent_id, due_at, recurrence) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING " + todoColumns
+	createdTodo, err := scanTodo(q.QueryRow(query, todo.Title, todo.Description, todo.Status, todo.UserID, todo.WorkspaceID, todo.ListID, todo.ParentID, todo.DueAt, todo.Recurrence))
 	if err != nil {
 		fmt.Println(err)
 		return nil, err
 	}
 
-	createdTodo := &Todo{
-		ID:     todoID,
-		Title:  title,
-		Status: status,
-		UserID: userID,
+	if createdTodo.Recurrence != nil {
+		query := "UPDATE todos SET series_id = id, occurrence = 1 WHERE id = $1 RETURNING " + todoColumns
+		if createdTodo, err = scanTodo(q.QueryRow(query, createdTodo.ID)); err != nil {
+			return nil, err
+		}
 	}
 
 	return createdTodo, nil
 }
 
-// UpdateTodo updates an existing todo in the database.
-func (ts *TodoStore) UpdateTodo(todoID int, title, status string, userId int) (*Todo, error) {
-	var updatedTodo Todo
-	query := "UPDATE todos SET title = COALESCE(NULLIF($2, ''), title), status = COALESCE(NULLIF($3, ''), status) WHERE id = $1 RETURNING id, title, status"
-	err := ts.DB.QueryRow(query, todoID, title, status).Scan(&updatedTodo.ID, &updatedTodo.Title, &updatedTodo.Status)
+// TodoFields are the fields of a todo that are edited directly, as opposed to
+// through dedicated operations such as nesting, scheduling or moving it.
+type TodoFields struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	DueAt       *time.Time `json:"due_at"`
+	ListID      *int       `json:"list_id"`
+}
+
+// Fields returns the directly edited fields of a todo.
+func (t *Todo) Fields() TodoFields {
+	return TodoFields{Title: t.Title, Description: t.Description, Status: t.Status, DueAt: t.DueAt, ListID: t.ListID}
+}
+
+// UpdateTodo updates an existing todo in the database. Empty titles and
+// statuses and a nil description are left unchanged; use EditTodo to clear
+// fields. Unless ifVersions is nil, the todo must be at one of those versions.
+func (ts *TodoStore) UpdateTodo(todoID int, title, status string, description *string, userId int, ifVersions []int) (*Todo, error) {
+	return ts.EditTodo(todoID, func(fields TodoFields) (TodoFields, error) {
+		if title != "" {
+			fields.Title = title
+		}
+		if status != "" {
+			fields.Status = status
+		}
+		if description != nil {
+			fields.Description = *description
+		}
+		return fields, nil
+	}, userId, ifVersions)
+}
+
+// EditTodo sets the fields of a todo to those returned by edit, which is
+// called with the current fields while the todo is locked, so edits derived
+// from them cannot overwrite concurrent changes. An error from edit aborts the
+// change and is returned as is. Status changes are subject to
+// checkStatusChange and trigger the follow-ups of afterStatusChange, and the
+// change is recorded in the todo's history as made by userID. Unless
+// ifVersions is nil, the todo must be at one of those versions.
+func (ts *TodoStore) EditTodo(todoID int, edit func(TodoFields) (TodoFields, error), userID int, ifVersions []int) (*Todo, error) {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	updatedTodo, err := ts.editTodo(tx, todoID, edit, userID, ifVersions)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ts.loadTodo(updatedTodo, nil)
+}
+
+// editTodo edits a todo as part of a transaction.
+func (ts *TodoStore) editTodo(tx queryer, todoID int, edit func(TodoFields) (TodoFields, error), userID int, ifVersions []int) (*Todo, error) {
+	return ts.changeTodo(tx, todoID, func(todo *Todo) error {
+		fields, err := edit(todo.Fields())
+		if err != nil {
+			return err
+		}
+
+		todo.Title, todo.Description, todo.Status = fields.Title, fields.Description, fields.Status
+		todo.DueAt, todo.ListID = fields.DueAt, fields.ListID
+		return nil
+	}, userID, ifVersions, nil)
+}
+
+// changeTodo is the single path through which the editable fields of a todo
+// change, as part of a transaction. change is called with a copy of the todo
+// while it is locked and sets the fields to store. A new parent is checked
+// with validateParent, a recurrence starts a series, status changes are
+// subject to checkStatusChange and trigger the follow-ups of
+// afterStatusChange, and the change is recorded in the todo's history as made
+// by userID, as a revert of revertedFrom if not nil.
+func (ts *TodoStore) changeTodo(tx queryer, todoID int, change func(*Todo) error, userID int, ifVersions []int, revertedFrom *int) (*Todo, error) {
+	previous, err := scanTodo(tx.QueryRow("SELECT "+todoColumns+" FROM todos WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", todoID))
+	if err != nil {
+		return nil, err
+	}
+	if !versionMatches(previous.Version, ifVersions) {
+		return nil, ErrVersionMismatch
+	}
+
+	todo := *previous
+	if err := change(&todo); err != nil {
+		return nil, err
+	}
+
+	if todo.ParentID != nil && !equalInts(previous.ParentID, todo.ParentID) {
+		if err := validateParent(tx, todoID, *todo.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	if todo.Recurrence != nil && todo.SeriesID == nil {
+		occurrence := 1
+		todo.SeriesID, todo.Occurrence = &todo.ID, &occurrence
+	}
+
+	if err := checkStatusChange(tx, todoID, previous.Status, todo.Status); err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE todos SET title = $2, description = $3, status = $4, due_at = $5, list_id = $6,
+		parent_id = $7, recurrence = $8, series_id = $9, occurrence = $10
+		WHERE id = $1 RETURNING ` + todoColumns
+	updatedTodo, err := scanTodo(tx.QueryRow(query, todoID, todo.Title, todo.Description, todo.Status, todo.DueAt, todo.ListID,
+		todo.ParentID, todo.Recurrence, todo.SeriesID, todo.Occurrence))
 	if err != nil {
 		return nil, err
 	}
 
-	newUpdatedTodo := &Todo{
-		ID:     updatedTodo.ID,
-		Title:  updatedTodo.Title,
-		Status: updatedTodo.Status,
-		UserID: userId,
+	if err := ts.afterStatusChange(tx, previous.Status, updatedTodo, userID); err != nil {
+		return nil, err
+	}
+
+	if err := recordRevision(tx, previous, updatedTodo, userID, revertedFrom); err != nil {
+		return nil, err
+	}
+
+	return updatedTodo, nil
+}
+
+// checkStatusChange rejects moving a todo with open blockers to in progress or
+// done, and moving a todo into a board column that is at its WIP limit.
+func checkStatusChange(q queryer, todoID int, previousStatus, status string) error {
+	if status == previousStatus {
+		return nil
+	}
+
+	if status == TodoStatusInProgress || status == TodoStatusDone {
+		var blocked bool
+		if err := q.QueryRow("SELECT EXISTS ("+openBlockersQuery+")", todoID, TodoStatusDone).Scan(&blocked); err != nil {
+			return err
+		}
+		if blocked {
+			return ErrTodoBlocked
+		}
+	}
+
+	return checkWIPLimits(q, todoID, status)
+}
+
+// afterStatusChange runs the follow-ups of a status change made by userID:
+// completing an occurrence of a recurring todo creates the next occurrence,
+// and completing the last open subtask of a todo completes the todo when
+// AutoCompleteParents is set.
+func (ts *TodoStore) afterStatusChange(q queryer, previousStatus string, todo *Todo, userID int) error {
+	if previousStatus != TodoStatusDone && todo.Status == TodoStatusDone && todo.Recurrence != nil {
+		if err := createNextOccurrence(q, todo); err != nil {
+			return err
+		}
+	}
+
+	if ts.AutoCompleteParents && todo.Status == TodoStatusDone && todo.ParentID != nil {
+		if err := ts.completeParent(q, *todo.ParentID, userID); err != nil {
+			return err
+		}
 	}
 
-	return newUpdatedTodo, nil
+	return nil
+}
+
+// SetTodoList moves a todo into a list on behalf of a user. A nil listID
+// removes it from its current list.
+func (ts *TodoStore) SetTodoList(todoID int, listID *int, userID int) (*Todo, error) {
+	return ts.EditTodo(todoID, func(fields TodoFields) (TodoFields, error) {
+		fields.ListID = listID
+		return fields, nil
+	}, userID, nil)
 }
 
-// DeleteTodo deletes a todo from the database.
-func (ts *TodoStore) DeleteTodo(todoID int) error {
-	query := "DELETE FROM todos WHERE id = $1"
-	_, err := ts.DB.Exec(query, todoID)
+// DeleteTodo moves a todo and its subtasks to the trash on behalf of a user.
+// Subtasks already in the trash keep their original deletion time. Unless
+// ifVersions is nil, the todo must be at one of those versions.
+func (ts *TodoStore) DeleteTodo(todoID, userID int, ifVersions []int) error {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteTodo(tx, todoID, userID, ifVersions); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deleteTodo moves a todo to the trash as part of a transaction.
+func deleteTodo(tx queryer, todoID, userID int, ifVersions []int) error {
+	var version int
+	err := tx.QueryRow("SELECT version FROM todos WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", todoID).Scan(&version)
+	if err != nil {
+		return err
+	}
+	if !versionMatches(version, ifVersions) {
+		return ErrVersionMismatch
+	}
+
+	query := `WITH RECURSIVE tree AS (
+			SELECT id FROM todos WHERE id = $1
+			UNION
+			SELECT t.id FROM todos t JOIN tree ON t.parent_id = tree.id
+		)
+		UPDATE todos SET deleted_at = now(), deleted_by = $2
+		WHERE id IN (SELECT id FROM tree) AND deleted_at IS NULL`
+	_, err = tx.Exec(query, todoID, userID)
 	return err
 }
+
+// versionMatches reports whether version is one of versions, or versions is nil.
+func versionMatches(version int, versions []int) bool {
+	if versions == nil {
+		return true
+	}
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
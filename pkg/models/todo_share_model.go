@@ -0,0 +1,117 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// TodoShare grants a single user read or edit access to a todo.
+type TodoShare struct {
+	TodoID   int    `json:"todo_id"`
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Level    string `json:"level"`
+}
+
+// SharedTodo is a todo shared with the requesting user, along with the level it was shared at.
+type SharedTodo struct {
+	Todo
+	SharedBy   string `json:"shared_by"`
+	ShareLevel string `json:"share_level"`
+}
+
+// IsValidShareLevel reports whether level can be granted through a share.
+func IsValidShareLevel(level string) bool {
+	return level == TodoAccessRead || level == TodoAccessEdit
+}
+
+// TodoShareStore is responsible for interacting with the todo share data in the database.
+type TodoShareStore struct {
+	DB *sql.DB
+}
+
+// NewTodoShareStore creates a new TodoShareStore instance.
+func NewTodoShareStore(db *sql.DB) *TodoShareStore {
+	return &TodoShareStore{DB: db}
+}
+
+// ShareTodo shares a todo with a user, replacing the level of any existing share.
+func (ss *TodoShareStore) ShareTodo(todoID, userID int, level string) error {
+	query := `INSERT INTO todo_shares(todo_id, user_id, level) VALUES($1, $2, $3)
+		ON CONFLICT (todo_id, user_id) DO UPDATE SET level = EXCLUDED.level`
+	_, err := ss.DB.Exec(query, todoID, userID, level)
+	return err
+}
+
+// GetSharesByTodoID retrieves the shares of a todo.
+func (ss *TodoShareStore) GetSharesByTodoID(todoID int) ([]TodoShare, error) {
+	shares := []TodoShare{}
+	query := `SELECT s.todo_id, s.user_id, u.username, s.level FROM todo_shares s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.todo_id = $1 ORDER BY u.username`
+	rows, err := ss.DB.Query(query, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var share TodoShare
+		if err := rows.Scan(&share.TodoID, &share.UserID, &share.Username, &share.Level); err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+
+	return shares, rows.Err()
+}
+
+// GetTodosSharedWithUser retrieves the todos other users have shared with a user.
+func (ss *TodoShareStore) GetTodosSharedWithUser(userID int) ([]SharedTodo, error) {
+	query := `SELECT ` + todoColumns + `, shared_by, share_level FROM (
+			SELECT t.*, u.username AS shared_by, s.level AS share_level, s.created_at AS shared_at
+			FROM todo_shares s
+			JOIN todos t ON t.id = s.todo_id
+			JOIN users u ON u.id = t.user_id
+			WHERE s.user_id = $1 AND t.deleted_at IS NULL
+		) shared ORDER BY shared_at DESC, id`
+	rows, err := ss.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todos []Todo
+	var sharedBy, shareLevels []string
+	for rows.Next() {
+		var by, level string
+		todo, err := scanTodo(rows, &by, &level)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, *todo)
+		sharedBy = append(sharedBy, by)
+		shareLevels = append(shareLevels, level)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	todoStore := TodoStore{DB: ss.DB}
+	if err := todoStore.loadTodoDetails(todos); err != nil {
+		return nil, err
+	}
+
+	sharedTodos := make([]SharedTodo, len(todos))
+	for i := range todos {
+		sharedTodos[i] = SharedTodo{Todo: todos[i], SharedBy: sharedBy[i], ShareLevel: shareLevels[i]}
+	}
+
+	return sharedTodos, nil
+}
+
+// RevokeShare removes a user's share of a todo.
+func (ss *TodoShareStore) RevokeShare(todoID, userID int) error {
+	query := "DELETE FROM todo_shares WHERE todo_id = $1 AND user_id = $2"
+	_, err := ss.DB.Exec(query, todoID, userID)
+	return err
+}
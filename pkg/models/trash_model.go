@@ -0,0 +1,101 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrParentInTrash is returned when a subtask is restored while its parent is still in the trash.
+var ErrParentInTrash = errors.New("the parent todo is in the trash; restore it first")
+
+// GetTrash retrieves a page of the todos in the trash that a user can read, most recently deleted first.
+func (ts *TodoStore) GetTrash(userID, limit, offset int) ([]Todo, error) {
+	query := "SELECT " + todoColumns + " FROM todos t WHERE " + accessibleTodoCondition + ` AND t.deleted_at IS NOT NULL
+		ORDER BY t.deleted_at DESC, t.id LIMIT $2 OFFSET $3`
+	return ts.queryTodos(query, userID, limit, offset)
+}
+
+// GetDeletedTodoByID retrieves a todo in the trash by its ID.
+func (ts *TodoStore) GetDeletedTodoByID(todoID int) (*Todo, error) {
+	query := "SELECT " + todoColumns + " FROM todos WHERE id = $1 AND deleted_at IS NOT NULL"
+	return ts.loadTodo(scanTodo(ts.DB.QueryRow(query, todoID)))
+}
+
+// RestoreTodo takes a todo out of the trash together with the subtasks that
+// were deleted with it. Subtasks deleted on their own earlier stay in the trash.
+func (ts *TodoStore) RestoreTodo(todoID int) (*Todo, error) {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	var parentDeleted bool
+	query := `SELECT t.deleted_at, p.deleted_at IS NOT NULL FROM todos t
+		LEFT JOIN todos p ON p.id = t.parent_id
+		WHERE t.id = $1 AND t.deleted_at IS NOT NULL FOR UPDATE OF t`
+	if err := tx.QueryRow(query, todoID).Scan(&deletedAt, &parentDeleted); err != nil {
+		return nil, err
+	}
+	if parentDeleted {
+		return nil, ErrParentInTrash
+	}
+
+	query = `WITH RECURSIVE tree AS (
+			SELECT id FROM todos WHERE id = $1
+			UNION
+			SELECT t.id FROM todos t JOIN tree ON t.parent_id = tree.id WHERE t.deleted_at = $2
+		)
+		UPDATE todos SET deleted_at = NULL, deleted_by = NULL WHERE id IN (SELECT id FROM tree)`
+	if _, err := tx.Exec(query, todoID, deletedAt); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ts.GetTodoByID(todoID)
+}
+
+// PurgeTodo permanently deletes a todo in the trash together with its subtasks.
+// It returns sql.ErrNoRows if the todo is not in the trash.
+func (ts *TodoStore) PurgeTodo(todoID int) error {
+	query := `WITH RECURSIVE tree AS (
+			SELECT id FROM todos WHERE id = $1 AND deleted_at IS NOT NULL
+			UNION
+			SELECT t.id FROM todos t JOIN tree ON t.parent_id = tree.id
+		)
+		DELETE FROM todos WHERE id IN (SELECT id FROM tree)`
+	result, err := ts.DB.Exec(query, todoID)
+	if err != nil {
+		return err
+	}
+
+	if deleted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// PurgeTrash permanently deletes the todos that have been in the trash for
+// longer than retention, with their subtasks, and returns how many were deleted.
+func (ts *TodoStore) PurgeTrash(retention time.Duration) (int64, error) {
+	query := `WITH RECURSIVE tree AS (
+			SELECT id FROM todos WHERE deleted_at < now() - make_interval(secs => $1)
+			UNION
+			SELECT t.id FROM todos t JOIN tree ON t.parent_id = tree.id
+		)
+		DELETE FROM todos WHERE id IN (SELECT id FROM tree)`
+	result, err := ts.DB.Exec(query, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
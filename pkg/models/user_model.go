@@ -58,6 +58,18 @@ func (us *UserStore) GetUserByID(userID int) (*User, error) {
 	return &user, nil
 }
 
+// GetUserByUsername retrieves a user by their username.
+func (us *UserStore) GetUserByUsername(username string) (*User, error) {
+	var user User
+	query := "SELECT id, username, role FROM users WHERE username = $1"
+	err := us.DB.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
 // CreateUser creates a new user in the database.
 func (us *UserStore) CreateUser(username, password, role string) (*User, error) {
 
@@ -101,6 +113,40 @@ func (us *UserStore) UpdateUser(userID int, username, password, role string) (*U
 	return updatedUser, nil
 }
 
+// SetUserRole changes the role of a user and returns the updated user together
+// with their previous role. It fails with ErrLastAdmin if no user would keep
+// PermUserAdmin.
+func (us *UserStore) SetUserRole(userID int, role string) (*User, string, error) {
+	tx, err := us.DB.Begin()
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	if err := lockAdmins(tx); err != nil {
+		return nil, "", err
+	}
+
+	user := User{ID: userID, Role: role}
+	var previousRole string
+	query := `UPDATE users u SET role = $2 FROM (SELECT role FROM users WHERE id = $1 FOR UPDATE) previous
+		WHERE u.id = $1 RETURNING u.username, previous.role`
+	err = tx.QueryRow(query, userID, role).Scan(&user.Username, &previousRole)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := ensureAdmin(tx); err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+
+	return &user, previousRole, nil
+}
+
 // DeleteUser deletes a user from the database.
 func (us *UserStore) DeleteUser(userID int) error {
 	query := "DELETE FROM users WHERE id = $1"
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Workspace roles, from most to least privileged.
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleEditor = "editor"
+	WorkspaceRoleViewer = "viewer"
+)
+
+// ErrLastWorkspaceOwner is returned when an operation would leave a workspace without an owner.
+var ErrLastWorkspaceOwner = errors.New("workspace must keep at least one owner")
+
+var workspaceRoleRank = map[string]int{
+	WorkspaceRoleViewer: 1,
+	WorkspaceRoleEditor: 2,
+	WorkspaceRoleOwner:  3,
+}
+
+// IsValidWorkspaceRole reports whether role is a known workspace role.
+func IsValidWorkspaceRole(role string) bool {
+	_, ok := workspaceRoleRank[role]
+	return ok
+}
+
+// WorkspaceRoleAtLeast reports whether role grants at least the privileges of minRole.
+func WorkspaceRoleAtLeast(role, minRole string) bool {
+	return workspaceRoleRank[role] > 0 && workspaceRoleRank[role] >= workspaceRoleRank[minRole]
+}
+
+// Workspace represents a group of users sharing todos.
+type Workspace struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role,omitempty"`
+}
+
+// WorkspaceMember represents a user's membership in a workspace.
+type WorkspaceMember struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// WorkspaceStore is responsible for interacting with the workspace data in the database.
+type WorkspaceStore struct {
+	DB *sql.DB
+}
+
+// NewWorkspaceStore creates a new WorkspaceStore instance.
+func NewWorkspaceStore(db *sql.DB) *WorkspaceStore {
+	return &WorkspaceStore{DB: db}
+}
+
+// CreateWorkspace creates a new workspace owned by the given user.
+func (ws *WorkspaceStore) CreateWorkspace(name string, ownerID int) (*Workspace, error) {
+	tx, err := ws.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var workspaceID int
+	err = tx.QueryRow("INSERT INTO workspaces(name) VALUES($1) RETURNING id", name).Scan(&workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "INSERT INTO workspace_members(workspace_id, user_id, role) VALUES($1, $2, $3)"
+	if _, err := tx.Exec(query, workspaceID, ownerID, WorkspaceRoleOwner); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &Workspace{ID: workspaceID, Name: name, Role: WorkspaceRoleOwner}, nil
+}
+
+// GetWorkspacesByUserID retrieves the workspaces a user is a member of.
+func (ws *WorkspaceStore) GetWorkspacesByUserID(userID int) ([]Workspace, error) {
+	workspaces := []Workspace{}
+	query := `SELECT w.id, w.name, m.role FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = $1 ORDER BY w.name, w.id`
+	rows, err := ws.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var workspace Workspace
+		if err := rows.Scan(&workspace.ID, &workspace.Name, &workspace.Role); err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+
+	return workspaces, rows.Err()
+}
+
+// GetMemberRole retrieves a user's role in a workspace, or an empty string if they are not a member.
+func (ws *WorkspaceStore) GetMemberRole(workspaceID, userID int) (string, error) {
+	var role string
+	query := "SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2"
+	err := ws.DB.QueryRow(query, workspaceID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return role, nil
+}
+
+// GetMembers retrieves the members of a workspace.
+func (ws *WorkspaceStore) GetMembers(workspaceID int) ([]WorkspaceMember, error) {
+	members := []WorkspaceMember{}
+	query := `SELECT m.user_id, u.username, m.role FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1 ORDER BY u.username`
+	rows, err := ws.DB.Query(query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var member WorkspaceMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.Role); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// SetMember adds a user to a workspace or changes their role if they are already a member.
+func (ws *WorkspaceStore) SetMember(workspaceID, userID int, role string) error {
+	tx, err := ws.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO workspace_members(workspace_id, user_id, role) VALUES($1, $2, $3)
+		ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role`
+	if _, err := tx.Exec(query, workspaceID, userID, role); err != nil {
+		return err
+	}
+
+	if err := ensureWorkspaceOwner(tx, workspaceID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveMember removes a user from a workspace.
+func (ws *WorkspaceStore) RemoveMember(workspaceID, userID int) error {
+	tx, err := ws.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2"
+	if _, err := tx.Exec(query, workspaceID, userID); err != nil {
+		return err
+	}
+
+	if err := ensureWorkspaceOwner(tx, workspaceID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ensureWorkspaceOwner locks the workspace and fails if it no longer has an owner.
+func ensureWorkspaceOwner(tx *sql.Tx, workspaceID int) error {
+	if _, err := tx.Exec("SELECT id FROM workspaces WHERE id = $1 FOR UPDATE", workspaceID); err != nil {
+		return err
+	}
+
+	var owners int
+	query := "SELECT COUNT(*) FROM workspace_members WHERE workspace_id = $1 AND role = $2"
+	if err := tx.QueryRow(query, workspaceID, WorkspaceRoleOwner).Scan(&owners); err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastWorkspaceOwner
+	}
+
+	return nil
+}
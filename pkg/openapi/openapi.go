@@ -0,0 +1,135 @@
+// Package openapi holds the OpenAPI 3 description of the API, with a page to
+// browse it, and checks the API against it.
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Spec is the OpenAPI document of the API. It must describe every route
+// registered by the server package; CheckRoutes reports any that it does not.
+//
+//go:embed openapi.json
+var Spec []byte
+
+//go:embed ui
+var uiFiles embed.FS
+
+// UI returns the files of the documentation page, which renders Spec.
+func UI() fs.FS {
+	ui, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err)
+	}
+	return ui
+}
+
+// Document is the part of an OpenAPI document needed to check the API against it.
+type Document struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas   map[string]*Schema   `json:"schemas"`
+		Responses map[string]*Response `json:"responses"`
+	} `json:"components"`
+}
+
+// Operation describes one method of a path.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Response describes a response of an operation, or refers to a shared one with Ref.
+type Response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]MediaType `json:"content"`
+}
+
+// MediaType describes the body of a response in one content type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of OpenAPI schemas used by Spec. A schema without a
+// type accepts any value.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []interface{}      `json:"enum"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	AllOf                []*Schema          `json:"allOf"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+}
+
+// Load parses Spec.
+func Load() (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(Spec, &doc); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI document: %w", err)
+	}
+
+	return &doc, nil
+}
+
+// operation returns the operation of method on the path template path, if documented.
+func (d *Document) operation(method, path string) *Operation {
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+// CheckRoutes verifies that the routes registered on router and the
+// operations of the document match, reporting every route that is not
+// documented and every operation that has no route.
+func (d *Document) CheckRoutes(router *mux.Router) error {
+	registered := map[string]bool{}
+	var problems []string
+
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("route %s has no methods", path))
+			return nil
+		}
+
+		for _, method := range methods {
+			registered[method+" "+path] = true
+			if d.operation(method, path) == nil {
+				problems = append(problems, fmt.Sprintf("route %s %s is not documented", method, path))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for path, operations := range d.Paths {
+		for method := range operations {
+			if !registered[strings.ToUpper(method)+" "+path] {
+				problems = append(problems, fmt.Sprintf("operation %s %s has no route", strings.ToUpper(method), path))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New("the OpenAPI document does not match the routes: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
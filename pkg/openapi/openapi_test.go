@@ -0,0 +1,295 @@
+package openapi_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/proGabby/simple_auth_todo_api/pkg/data/database"
+	"github.com/proGabby/simple_auth_todo_api/pkg/openapi"
+	"github.com/proGabby/simple_auth_todo_api/pkg/server"
+)
+
+// apiTester calls the API and checks every response against the document.
+type apiTester struct {
+	t        *testing.T
+	document *openapi.Document
+	handler  http.Handler
+	token    string
+	called   map[string]bool
+}
+
+// call sends a request to the operation at method and the path template
+// template, with path filling in the template, and fails the test if the
+// response does not have status want or is not described by the document.
+func (a *apiTester) call(method, template, path, contentType string, body []byte, want int) []byte {
+	a.t.Helper()
+	a.called[method+" "+template] = true
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+
+	if rec.Code != want {
+		a.t.Fatalf("%s %s: status %d, want %d: %s", method, path, rec.Code, want, rec.Body.String())
+	}
+	err := a.document.ValidateResponse(method, template, rec.Code, rec.Header().Get("Content-Type"), rec.Body.Bytes())
+	if err != nil {
+		a.t.Errorf("%s %s: %v", method, path, err)
+	}
+
+	return rec.Body.Bytes()
+}
+
+// callJSON is call with value sent as a JSON body, if not nil.
+func (a *apiTester) callJSON(method, template, path string, value interface{}, want int) []byte {
+	a.t.Helper()
+	if value == nil {
+		return a.call(method, template, path, "", nil, want)
+	}
+
+	body, err := json.Marshal(value)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.call(method, template, path, "application/json", body, want)
+}
+
+// decode unmarshals a response body into value.
+func (a *apiTester) decode(body []byte, value interface{}) {
+	a.t.Helper()
+	if err := json.Unmarshal(body, value); err != nil {
+		a.t.Fatalf("decoding %s: %v", body, err)
+	}
+}
+
+// id returns the id member of a response body.
+func (a *apiTester) id(body []byte) int {
+	a.t.Helper()
+	var resource struct {
+		ID int `json:"id"`
+	}
+	a.decode(body, &resource)
+	return resource.ID
+}
+
+// newTestServer assembles the API on the database in DATABASE_URL, skipping
+// the test when it is not set.
+func newTestServer(t *testing.T) (*server.Server, *sql.DB) {
+	t.Helper()
+	connStr := os.Getenv("DATABASE_URL")
+	if connStr == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+	if os.Getenv("JWT_SECRET_KEY") == "" {
+		t.Setenv("JWT_SECRET_KEY", "test-secret")
+	}
+	t.Setenv("ATTACHMENT_STORAGE", "local")
+	t.Setenv("ATTACHMENT_DIR", t.TempDir())
+	t.Setenv("RATE_LIMIT_STORE", "memory")
+
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := server.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, db
+}
+
+func TestResponsesMatchDocument(t *testing.T) {
+	srv, db := newTestServer(t)
+	document, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &apiTester{t: t, document: document, handler: srv.Handler, called: map[string]bool{}}
+
+	// Users: the owner of everything below, made an admin, and a user to share with
+	suffix := time.Now().UnixNano()
+	owner := fmt.Sprintf("openapi-owner-%d", suffix)
+	other := fmt.Sprintf("openapi-other-%d", suffix)
+	a.callJSON("POST", "/register", "/register", map[string]string{"username": owner, "password": "secret"}, http.StatusOK)
+	otherID := a.id(a.callJSON("POST", "/register", "/register", map[string]string{"username": other, "password": "secret"}, http.StatusOK))
+	if _, err := db.Exec("UPDATE users SET role = 'admin' WHERE username = $1", owner); err != nil {
+		t.Fatal(err)
+	}
+	a.callJSON("POST", "/login", "/login", map[string]string{"username": owner, "password": "wrong"}, http.StatusUnauthorized)
+	var login struct {
+		Token string `json:"token"`
+	}
+	a.decode(a.callJSON("POST", "/login", "/login", map[string]string{"username": owner, "password": "secret"}, http.StatusOK), &login)
+	a.callJSON("GET", "/user/details", "/user/details", nil, http.StatusUnauthorized)
+	a.token = login.Token
+	a.callJSON("GET", "/user/details", "/user/details", nil, http.StatusOK)
+
+	// Lists
+	listID := a.id(a.callJSON("POST", "/lists", "/lists", map[string]string{"name": "Errands", "color": "#336699"}, http.StatusOK))
+	listPath := fmt.Sprintf("/lists/%d", listID)
+	a.callJSON("GET", "/lists", "/lists", nil, http.StatusOK)
+	a.callJSON("GET", "/lists/{id}", listPath, nil, http.StatusOK)
+	a.callJSON("PUT", "/lists/{id}", listPath, map[string]string{"name": "Chores"}, http.StatusOK)
+
+	// Todos
+	todoID := a.id(a.callJSON("POST", "/todos", "/todos", map[string]interface{}{"title": "Buy milk", "list_id": listID}, http.StatusOK))
+	otherTodoID := a.id(a.callJSON("POST", "/todos", "/todos", map[string]string{"title": "Bake bread"}, http.StatusOK))
+	todoPath := fmt.Sprintf("/todos/%d", todoID)
+	otherTodoPath := fmt.Sprintf("/todos/%d", otherTodoID)
+	a.callJSON("GET", "/todos", "/todos", nil, http.StatusOK)
+	a.callJSON("GET", "/todos", "/todos?shared=sometimes", nil, http.StatusBadRequest)
+	a.callJSON("GET", "/todos/shared", "/todos/shared", nil, http.StatusOK)
+	a.callJSON("GET", "/todos/search", "/todos/search?q=milk", nil, http.StatusOK)
+	a.callJSON("GET", "/todos/next", "/todos/next", nil, http.StatusOK)
+	a.callJSON("GET", "/todos/{id}", todoPath, nil, http.StatusOK)
+	a.callJSON("GET", "/todos/{id}", "/todos/0", nil, http.StatusNotFound)
+	a.callJSON("PUT", "/todos/update", fmt.Sprintf("/todos/update?id=%d", todoID), map[string]string{"title": "Buy oat milk", "status": "in_progress"}, http.StatusOK)
+	a.callJSON("PUT", "/todos/{id}", todoPath, map[string]interface{}{"title": "Buy milk", "status": "active", "list_id": listID}, http.StatusOK)
+	a.call("PATCH", "/todos/{id}", todoPath, "application/merge-patch+json", []byte(`{"description":"Two litres"}`), http.StatusOK)
+	a.callJSON("POST", "/todos/bulk", "/todos/bulk", map[string]interface{}{
+		"operations": []map[string]interface{}{{"op": "create", "todo": map[string]string{"title": "Bulk todo"}}},
+	}, http.StatusOK)
+	a.callJSON("GET", "/lists/{id}/todos", listPath+"/todos", nil, http.StatusOK)
+
+	// Sharing
+	a.callJSON("POST", "/todos/{id}/shares", todoPath+"/shares", map[string]string{"username": other, "level": "read"}, http.StatusOK)
+	a.callJSON("GET", "/todos/{id}/shares", todoPath+"/shares", nil, http.StatusOK)
+	a.callJSON("DELETE", "/todos/{id}/shares/{userID}", fmt.Sprintf("%s/shares/%d", todoPath, otherID), nil, http.StatusOK)
+
+	// Subtasks, recurrence and dependencies
+	subtaskID := a.id(a.callJSON("POST", "/todos/{id}/subtasks", todoPath+"/subtasks", map[string]string{"title": "Find a shop"}, http.StatusOK))
+	a.callJSON("GET", "/todos/{id}/subtasks", todoPath+"/subtasks", nil, http.StatusOK)
+	a.callJSON("PUT", "/todos/{id}/parent", fmt.Sprintf("/todos/%d/parent", subtaskID), map[string]interface{}{"parent_id": nil}, http.StatusOK)
+	a.callJSON("PUT", "/todos/{id}/recurrence", otherTodoPath+"/recurrence", map[string]interface{}{
+		"recurrence": map[string]interface{}{"frequency": "weekly", "interval": 1},
+		"due_at":     time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339),
+	}, http.StatusOK)
+	a.callJSON("GET", "/todos/{id}/series", otherTodoPath+"/series", nil, http.StatusOK)
+	a.callJSON("POST", "/todos/{id}/dependencies", todoPath+"/dependencies", map[string]int{"depends_on_id": otherTodoID}, http.StatusOK)
+	a.callJSON("DELETE", "/todos/{id}/dependencies/{dependsOnID}", fmt.Sprintf("%s/dependencies/%d", todoPath, otherTodoID), nil, http.StatusOK)
+	a.callJSON("POST", "/todos/{id}/move", todoPath+"/move", map[string]int{"after_id": otherTodoID}, http.StatusOK)
+
+	// Comments
+	commentID := a.id(a.callJSON("POST", "/todos/{id}/comments", todoPath+"/comments", map[string]string{"body": "Semi-skimmed"}, http.StatusOK))
+	commentPath := fmt.Sprintf("%s/comments/%d", todoPath, commentID)
+	a.callJSON("GET", "/todos/{id}/comments", todoPath+"/comments", nil, http.StatusOK)
+	a.callJSON("PUT", "/todos/{id}/comments/{commentID}", commentPath, map[string]string{"body": "Whole"}, http.StatusOK)
+	a.callJSON("DELETE", "/todos/{id}/comments/{commentID}", commentPath, nil, http.StatusOK)
+
+	// Attachments
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	file, err := writer.CreateFormFile("file", "list.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("milk\n"))
+	writer.Close()
+	attachmentID := a.id(a.call("POST", "/todos/{id}/attachments", todoPath+"/attachments", writer.FormDataContentType(), form.Bytes(), http.StatusOK))
+	attachmentPath := fmt.Sprintf("%s/attachments/%d", todoPath, attachmentID)
+	a.callJSON("GET", "/todos/{id}/attachments", todoPath+"/attachments", nil, http.StatusOK)
+	a.callJSON("GET", "/todos/{id}/attachments/{attachmentID}", attachmentPath, nil, http.StatusOK)
+	a.callJSON("DELETE", "/todos/{id}/attachments/{attachmentID}", attachmentPath, nil, http.StatusOK)
+
+	// History
+	var history []struct {
+		Revision int `json:"revision"`
+	}
+	a.decode(a.callJSON("GET", "/todos/{id}/history", todoPath+"/history", nil, http.StatusOK), &history)
+	if len(history) == 0 {
+		t.Fatal("the todo has no history")
+	}
+	revisionPath := fmt.Sprintf("%s/history/%d", todoPath, history[len(history)-1].Revision)
+	a.callJSON("GET", "/todos/{id}/history/{revision}", revisionPath, nil, http.StatusOK)
+	a.callJSON("POST", "/todos/{id}/history/{revision}/revert", revisionPath+"/revert", nil, http.StatusOK)
+	a.callJSON("PUT", "/todos/{id}/list", todoPath+"/list", map[string]interface{}{"list_id": nil}, http.StatusOK)
+
+	// Tags
+	tagID := a.id(a.callJSON("POST", "/tags", "/tags", map[string]string{"name": "shopping"}, http.StatusOK))
+	a.callJSON("GET", "/tags", "/tags", nil, http.StatusOK)
+	a.callJSON("POST", "/todos/{id}/tags", todoPath+"/tags", map[string]int{"tag_id": tagID}, http.StatusOK)
+	a.callJSON("DELETE", "/todos/{id}/tags/{tagID}", fmt.Sprintf("%s/tags/%d", todoPath, tagID), nil, http.StatusOK)
+	a.callJSON("DELETE", "/tags/{id}", fmt.Sprintf("/tags/%d", tagID), nil, http.StatusOK)
+
+	// Boards
+	var board struct {
+		ID      int `json:"id"`
+		Columns []struct {
+			ID     int    `json:"id"`
+			Status string `json:"status"`
+		} `json:"columns"`
+	}
+	a.decode(a.callJSON("POST", "/boards", "/boards", map[string]string{"name": "Week"}, http.StatusOK), &board)
+	boardPath := fmt.Sprintf("/boards/%d", board.ID)
+	a.callJSON("GET", "/boards", "/boards", nil, http.StatusOK)
+	a.callJSON("GET", "/boards/{id}", boardPath, nil, http.StatusOK)
+	a.decode(a.callJSON("PUT", "/boards/{id}/columns", boardPath+"/columns", map[string]interface{}{
+		"columns": []map[string]interface{}{
+			{"name": "Backlog", "status": "active"},
+			{"name": "Doing", "status": "in_progress", "wip_limit": 3},
+			{"name": "Done", "status": "done"},
+		},
+	}, http.StatusOK), &board)
+	a.callJSON("POST", "/boards/{id}/move", boardPath+"/move", map[string]int{"todo_id": todoID, "column_id": board.Columns[1].ID}, http.StatusOK)
+	a.callJSON("DELETE", "/boards/{id}", boardPath, nil, http.StatusOK)
+
+	// Workspaces
+	workspaceID := a.id(a.callJSON("POST", "/workspaces", "/workspaces", map[string]string{"name": "Home"}, http.StatusOK))
+	workspacePath := fmt.Sprintf("/workspaces/%d", workspaceID)
+	a.callJSON("GET", "/workspaces", "/workspaces", nil, http.StatusOK)
+	a.callJSON("POST", "/workspaces/{id}/members", workspacePath+"/members", map[string]string{"username": other, "role": "viewer"}, http.StatusOK)
+	a.callJSON("GET", "/workspaces/{id}/members", workspacePath+"/members", nil, http.StatusOK)
+	a.callJSON("DELETE", "/workspaces/{id}/members/{userID}", fmt.Sprintf("%s/members/%d", workspacePath, otherID), nil, http.StatusOK)
+	a.callJSON("POST", "/workspaces/{id}/todos", workspacePath+"/todos", map[string]string{"title": "Water plants"}, http.StatusOK)
+	a.callJSON("GET", "/workspaces/{id}/todos", workspacePath+"/todos", nil, http.StatusOK)
+
+	// Trash
+	a.callJSON("DELETE", "/todos/{id}", otherTodoPath, nil, http.StatusOK)
+	a.callJSON("GET", "/trash", "/trash", nil, http.StatusOK)
+	a.callJSON("POST", "/todos/{id}/restore", otherTodoPath+"/restore", nil, http.StatusOK)
+	a.callJSON("DELETE", "/todos/{id}", otherTodoPath, nil, http.StatusOK)
+	a.callJSON("DELETE", "/trash/{id}", fmt.Sprintf("/trash/%d", otherTodoID), nil, http.StatusOK)
+	a.callJSON("DELETE", "/lists/{id}", listPath, nil, http.StatusOK)
+
+	// Administration
+	var permissions struct {
+		Roles map[string][]string `json:"roles"`
+	}
+	a.decode(a.callJSON("GET", "/admin/permissions", "/admin/permissions", nil, http.StatusOK), &permissions)
+	a.callJSON("PUT", "/admin/roles/{role}/permissions", "/admin/roles/user/permissions", map[string][]string{"permissions": permissions.Roles["user"]}, http.StatusOK)
+	a.callJSON("PUT", "/admin/users/{id}/role", fmt.Sprintf("/admin/users/%d/role", otherID), map[string]string{"role": "user"}, http.StatusOK)
+	a.callJSON("GET", "/admin/audit", "/admin/audit", nil, http.StatusOK)
+	a.callJSON("GET", "/admin/audit/export", "/admin/audit/export", nil, http.StatusOK)
+
+	// Documentation
+	a.callJSON("GET", "/openapi.json", "/openapi.json", nil, http.StatusOK)
+	a.callJSON("GET", "/docs/", "/docs/", nil, http.StatusOK)
+
+	for path, operations := range document.Paths {
+		for method := range operations {
+			if !a.called[strings.ToUpper(method)+" "+path] {
+				t.Errorf("operation %s %s was not called", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Renders the OpenAPI document of the API as a browsable page. Everything is
+// built with DOM methods, so the page works under a strict content security policy.
+"use strict";
+
+let spec;
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [name, value] of Object.entries(attrs || {})) {
+    node.setAttribute(name, value);
+  }
+  for (const child of children) {
+    if (child !== null && child !== undefined) {
+      node.append(child);
+    }
+  }
+  return node;
+}
+
+// resolve follows a local $ref to the object it refers to.
+function resolve(obj) {
+  while (obj && obj.$ref) {
+    obj = obj.$ref.replace(/^#\//, "").split("/").reduce((o, key) => o[key], spec);
+  }
+  return obj;
+}
+
+function refName(obj) {
+  return obj && obj.$ref ? obj.$ref.split("/").pop() : null;
+}
+
+// example builds a sample value of a schema, showing its shape.
+function example(schema, seen) {
+  const name = refName(schema);
+  if (name) {
+    if (seen.includes(name)) {
+      return "<" + name + ">";
+    }
+    seen = seen.concat(name);
+  }
+  schema = resolve(schema) || {};
+
+  if (schema.allOf) {
+    return Object.assign({}, ...schema.allOf.map((part) => example(part, seen)));
+  }
+  if (schema.enum) {
+    return schema.enum.join(" | ");
+  }
+  switch (schema.type) {
+    case "object": {
+      const value = {};
+      for (const [prop, propSchema] of Object.entries(schema.properties || {})) {
+        value[prop] = example(propSchema, seen);
+      }
+      if (schema.additionalProperties && !schema.properties) {
+        value["<name>"] = example(schema.additionalProperties, seen);
+      }
+      return value;
+    }
+    case "array":
+      return [example(schema.items || {}, seen)];
+    case "string":
+      return schema.format ? "<" + schema.format + ">" : "string";
+    case "integer":
+    case "number":
+    case "boolean":
+      return schema.type;
+    default:
+      return "any";
+  }
+}
+
+function schemaBlock(content) {
+  const blocks = [];
+  for (const [type, media] of Object.entries(content || {})) {
+    blocks.push(el("p", {}, el("code", {}, type), refName(media.schema) ? " — " + refName(media.schema) : ""));
+    if (media.schema) {
+      blocks.push(el("pre", {}, JSON.stringify(example(media.schema, []), null, 2)));
+    }
+  }
+  return blocks;
+}
+
+function renderOperation(method, path, operation) {
+  const body = el("div", { class: "operation" });
+  if (operation.description) {
+    body.append(el("p", {}, operation.description));
+  }
+  if (operation.security && operation.security.length === 0) {
+    body.append(el("p", {}, "No authentication required."));
+  }
+
+  const params = (operation.parameters || []).map(resolve);
+  if (params.length > 0) {
+    const table = el("table", {}, el("tr", {}, el("th", {}, "Parameter"), el("th", {}, "In"), el("th", {}, "Type"), el("th", {}, "Description")));
+    for (const param of params) {
+      const schema = resolve(param.schema) || {};
+      table.append(el("tr", {},
+        el("td", {}, el("code", {}, param.name), param.required ? " (required)" : ""),
+        el("td", {}, param.in),
+        el("td", {}, schema.enum ? schema.enum.join(" | ") : schema.type || ""),
+        el("td", {}, param.description || "")));
+    }
+    body.append(el("h4", {}, "Parameters"), table);
+  }
+
+  if (operation.requestBody) {
+    body.append(el("h4", {}, "Request body"), ...schemaBlock(resolve(operation.requestBody).content));
+  }
+
+  body.append(el("h4", {}, "Responses"));
+  for (const [code, ref] of Object.entries(operation.responses || {})) {
+    const response = resolve(ref);
+    body.append(el("p", {}, el("strong", {}, code), " " + (response.description || "")), ...schemaBlock(response.content));
+  }
+
+  const summary = el("summary", {},
+    el("span", { class: "method " + method }, method),
+    el("span", { class: "path" }, path), " — " + (operation.summary || ""));
+  const details = el("details", { class: operation.deprecated ? "deprecated" : "" }, summary, body);
+  details.dataset.search = (method + " " + path + " " + (operation.summary || "")).toLowerCase();
+  return details;
+}
+
+function render() {
+  document.title = spec.info.title;
+  document.getElementById("title").textContent = spec.info.title + " " + spec.info.version;
+  document.getElementById("description").textContent = spec.info.description || "";
+
+  const sections = new Map((spec.tags || []).map((tag) => [tag.name, []]));
+  for (const [path, item] of Object.entries(spec.paths)) {
+    for (const [method, operation] of Object.entries(item)) {
+      const tag = (operation.tags || ["Other"])[0];
+      if (!sections.has(tag)) {
+        sections.set(tag, []);
+      }
+      sections.get(tag).push(renderOperation(method, path, operation));
+    }
+  }
+
+  const main = document.getElementById("operations");
+  main.replaceChildren();
+  for (const [tag, operations] of sections) {
+    main.append(el("section", {}, el("h2", {}, tag), ...operations));
+  }
+}
+
+document.getElementById("filter").addEventListener("input", (event) => {
+  const words = event.target.value.toLowerCase().split(/\s+/).filter(Boolean);
+  for (const details of document.querySelectorAll("details")) {
+    const match = words.every((word) => details.dataset.search.includes(word));
+    details.classList.toggle("hidden", !match);
+  }
+});
+
+fetch("../openapi.json")
+  .then((response) => response.json())
+  .then((doc) => {
+    spec = doc;
+    render();
+  })
+  .catch((err) => {
+    document.getElementById("operations").textContent = "Could not load the OpenAPI document: " + err;
+  });
@@ -0,0 +1,186 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ValidateResponse checks that a response of the operation at method and the
+// path template path is documented: its status must be listed, and a JSON
+// body must match the schema of its content type. Bodies of other content
+// types are not checked.
+func (d *Document) ValidateResponse(method, path string, status int, contentType string, body []byte) error {
+	operation := d.operation(method, path)
+	if operation == nil {
+		return fmt.Errorf("%s %s is not documented", method, path)
+	}
+
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		return fmt.Errorf("status %d is not documented", status)
+	}
+	if response.Ref != "" {
+		name := strings.TrimPrefix(response.Ref, "#/components/responses/")
+		if response, ok = d.Components.Responses[name]; !ok {
+			return fmt.Errorf("unknown response %s", name)
+		}
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(response.Content) == 0 {
+		if len(body) > 0 {
+			return fmt.Errorf("status %d has no documented body", status)
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	content, ok := response.Content[mediaType]
+	if !ok {
+		// Downloads are documented as octet streams but sent with their own type
+		if content, ok = response.Content["application/octet-stream"]; !ok {
+			return fmt.Errorf("content type %q is not documented for status %d", mediaType, status)
+		}
+	}
+	if content.Schema == nil {
+		return nil
+	}
+
+	switch mediaType {
+	case "application/json":
+		return d.validateJSON(content.Schema, body)
+	case "application/x-ndjson":
+		for i, line := range bytes.Split(body, []byte("\n")) {
+			if err := d.validateJSON(content.Schema, line); err != nil {
+				return fmt.Errorf("line %d: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Document) validateJSON(schema *Schema, body []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+
+	return d.validate(schema, value, "body")
+}
+
+// validate checks value against schema, naming the value by path in errors.
+func (d *Document) validate(schema *Schema, value interface{}, path string) error {
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		resolved, ok := d.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema %s", path, name)
+		}
+		return d.validate(resolved, value, path)
+	}
+
+	for _, part := range schema.AllOf {
+		if err := d.validate(part, value, path); err != nil {
+			return err
+		}
+	}
+
+	if value == nil {
+		if schema.Type != "" && !schema.Nullable {
+			return fmt.Errorf("%s: is null", path)
+		}
+		return nil
+	}
+
+	if len(schema.Enum) > 0 && !containsValue(schema.Enum, value) {
+		return fmt.Errorf("%s: %v is not one of %v", path, value, schema.Enum)
+	}
+
+	switch schema.Type {
+	case "":
+		return nil
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: is not a string", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: is not a boolean", path)
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: is not a number", path)
+		}
+		if schema.Type == "integer" && strings.ContainsAny(number.String(), ".eE") {
+			return fmt.Errorf("%s: %s is not an integer", path, number)
+		}
+		f, _ := number.Float64()
+		if schema.Minimum != nil && f < *schema.Minimum {
+			return fmt.Errorf("%s: %s is less than %v", path, number, *schema.Minimum)
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			return fmt.Errorf("%s: %s is greater than %v", path, number, *schema.Maximum)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: is not an array", path)
+		}
+		if schema.Items != nil {
+			for i, item := range items {
+				if err := d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "object":
+		members, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: is not an object", path)
+		}
+		for _, name := range schema.Required {
+			if _, ok := members[name]; !ok {
+				return fmt.Errorf("%s: %s is missing", path, name)
+			}
+		}
+		for name, member := range members {
+			memberSchema, ok := schema.Properties[name]
+			if !ok {
+				memberSchema = schema.AdditionalProperties
+			}
+			if memberSchema == nil {
+				continue
+			}
+			if err := d.validate(memberSchema, member, path+"."+name); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema type %s", path, schema.Type)
+	}
+
+	return nil
+}
+
+// containsValue reports whether values includes value, comparing JSON numbers by value.
+func containsValue(values []interface{}, value interface{}) bool {
+	if number, ok := value.(json.Number); ok {
+		f, _ := number.Float64()
+		value = f
+	}
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
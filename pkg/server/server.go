@@ -0,0 +1,313 @@
+// Package server assembles the API from its stores, middlewares, controllers
+// and routes.
+package server
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/proGabby/simple_auth_todo_api/pkg/controllers"
+	"github.com/proGabby/simple_auth_todo_api/pkg/data/blobstore"
+	"github.com/proGabby/simple_auth_todo_api/pkg/data/ratelimit"
+	"github.com/proGabby/simple_auth_todo_api/pkg/middlewares"
+	"github.com/proGabby/simple_auth_todo_api/pkg/models"
+	"github.com/proGabby/simple_auth_todo_api/pkg/openapi"
+	"github.com/proGabby/simple_auth_todo_api/pkg/utils"
+)
+
+// Server is the API on top of a database.
+type Server struct {
+	// Router holds the routes of the API.
+	Router *mux.Router
+	// Handler serves the API, wrapping Router with the CORS and security headers middlewares.
+	Handler http.Handler
+
+	todoStore        *models.TodoStore
+	attachmentStore  *models.AttachmentStore
+	idempotencyStore *models.IdempotencyStore
+	blobs            blobstore.BlobStore
+	limits           ratelimit.Store
+	trashRetention   time.Duration
+}
+
+// New assembles the API on db, configured from the environment variables
+// described in the readme. The schema of db must be migrated.
+func New(db *sql.DB) (*Server, error) {
+	todoStore := models.NewTodoStore(db)
+	todoStore.AutoCompleteParents = os.Getenv("AUTO_COMPLETE_PARENT_TODOS") != "false"
+	userStore := models.NewUserStore(db)
+	permissionStore := models.NewPermissionStore(db)
+	workspaceStore := models.NewWorkspaceStore(db)
+	todoShareStore := models.NewTodoShareStore(db)
+	listStore := models.NewListStore(db)
+	tagStore := models.NewTagStore(db)
+	boardStore := models.NewBoardStore(db)
+	commentStore := models.NewCommentStore(db)
+	attachmentStore := models.NewAttachmentStore(db)
+	auditStore := models.NewAuditStore(db)
+	idempotencyStore := models.NewIdempotencyStore(db)
+	if window, err := time.ParseDuration(os.Getenv("COMMENT_EDIT_WINDOW")); err == nil {
+		commentStore.EditWindow = window
+	}
+
+	// Blob storage for attachments
+	blobs, err := blobstore.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	// How long todos stay in the trash before StartMaintenance purges them
+	trashRetention := 30 * 24 * time.Hour
+	if retention, err := time.ParseDuration(os.Getenv("TRASH_RETENTION")); err == nil {
+		trashRetention = retention
+	}
+
+	// Client addresses are taken from X-Forwarded-For only behind a trusted proxy
+	utils.TrustProxyHeaders = os.Getenv("TRUST_PROXY_HEADERS") == "true"
+
+	// Middleware for authentication
+	authMiddleware := middlewares.NewAuthMiddleware(*userStore, *auditStore)
+
+	// Middleware for replaying retried requests that carry an Idempotency-Key
+	idempotencyMiddleware := middlewares.NewIdempotencyMiddleware(*idempotencyStore)
+	if ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_KEY_TTL")); err == nil {
+		idempotencyMiddleware.TTL = ttl
+	}
+
+	// Middleware for rate limiting: sign-in routes per client address and the
+	// rest of the API per client address and per user
+	limits, err := ratelimit.FromEnv(db)
+	if err != nil {
+		return nil, err
+	}
+	authRateLimit, err := ratelimit.PolicyFromEnv("RATE_LIMIT_AUTH", ratelimit.Policy{Limit: 10, Window: time.Minute})
+	if err != nil {
+		return nil, err
+	}
+	apiRateLimit, err := ratelimit.PolicyFromEnv("RATE_LIMIT_API", ratelimit.Policy{Limit: 300, Window: time.Minute})
+	if err != nil {
+		return nil, err
+	}
+	apiIPRateLimit, err := ratelimit.PolicyFromEnv("RATE_LIMIT_API_IP", ratelimit.Policy{Limit: 1200, Window: time.Minute})
+	if err != nil {
+		return nil, err
+	}
+	rateLimitMiddleware := middlewares.NewRateLimitMiddleware(limits)
+
+	// authenticated wraps a route that needs a signed in user. Requests are
+	// limited per client address before authentication, so that requests with
+	// bad tokens are limited too, and per user after it.
+	authenticated := func(next http.HandlerFunc) http.HandlerFunc {
+		return rateLimitMiddleware.Limit("api-ip", apiIPRateLimit, authMiddleware.Authenticate(rateLimitMiddleware.Limit("api", apiRateLimit, next)))
+	}
+
+	// Middleware for permission
+	permissionMiddleware := middlewares.NewPermissionMiddleware(authMiddleware, *todoStore, *permissionStore, *workspaceStore, *listStore)
+
+	// Initialize controllers
+	todoController := controllers.NewTodoController(*todoStore, *listStore, *permissionStore)
+	todoController.RequireIfMatch = os.Getenv("REQUIRE_IF_MATCH") == "true"
+	userController := controllers.NewUserController(*userStore, *auditStore)
+	permissionController := controllers.NewPermissionController(*permissionStore, *userStore, *auditStore)
+	workspaceController := controllers.NewWorkspaceController(*workspaceStore, *userStore)
+	todoShareController := controllers.NewTodoShareController(*todoShareStore, *todoStore, *userStore)
+	listController := controllers.NewListController(*listStore)
+	tagController := controllers.NewTagController(*tagStore, *todoStore)
+	boardController := controllers.NewBoardController(*boardStore, *todoStore, *listStore)
+	commentController := controllers.NewCommentController(*commentStore)
+	auditController := controllers.NewAuditController(*auditStore)
+	docsController := controllers.NewDocsController(openapi.Spec, openapi.UI())
+	attachmentController := controllers.NewAttachmentController(*attachmentStore, blobs)
+	if maxSize, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_BYTES"), 10, 64); err == nil && maxSize > 0 {
+		attachmentController.MaxSize = maxSize
+	}
+
+	// Routes
+	r := mux.NewRouter()
+	r.HandleFunc("/login", rateLimitMiddleware.Limit("auth", authRateLimit, userController.LoginUser)).Methods("POST")
+	r.HandleFunc("/register", rateLimitMiddleware.Limit("auth", authRateLimit, idempotencyMiddleware.Idempotent(userController.RegisterUser))).Methods("POST")
+	r.HandleFunc("/user/details", authenticated(userController.GetUserByToken)).Methods("GET")
+	r.HandleFunc("/todos", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoRead}, todoController.GetTodosByUser))).Methods("GET")
+	r.HandleFunc("/todos", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoWrite}, idempotencyMiddleware.Idempotent(todoController.CreateTodo)))).Methods("POST")
+	r.HandleFunc("/todos/shared", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoRead}, todoShareController.GetSharedTodos))).Methods("GET")
+	r.HandleFunc("/todos/bulk", authenticated(permissionMiddleware.Authorize(nil, idempotencyMiddleware.Idempotent(todoController.BulkTodos)))).Methods("POST")
+	r.HandleFunc("/todos/search", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoRead}, todoController.SearchTodos))).Methods("GET")
+	r.HandleFunc("/todos/next", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoRead}, todoController.GetNextActionable))).Methods("GET")
+	r.HandleFunc("/todos/{id}", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoRead}, todoController.GetSingleTodo))).Methods("GET")
+	r.HandleFunc("/todos/update", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, todoController.UpdateTodo))).Methods("PUT")
+	r.HandleFunc("/todos/{id}", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, todoController.ReplaceTodo))).Methods("PUT")
+	r.HandleFunc("/todos/{id}", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, todoController.PatchTodo))).Methods("PATCH")
+	r.HandleFunc("/todos/{id}", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoDelete}, todoController.DeleteTodo))).Methods("DELETE")
+	r.HandleFunc("/todos/{id}/shares", authenticated(permissionMiddleware.AuthorizeTodoOwner([]string{models.PermTodoRead}, todoShareController.GetShares))).Methods("GET")
+	r.HandleFunc("/todos/{id}/shares", authenticated(permissionMiddleware.AuthorizeTodoOwner([]string{models.PermTodoWrite}, todoShareController.ShareTodo))).Methods("POST")
+	r.HandleFunc("/todos/{id}/shares/{userID}", authenticated(permissionMiddleware.AuthorizeTodoOwner([]string{models.PermTodoWrite}, todoShareController.RevokeShare))).Methods("DELETE")
+	r.HandleFunc("/todos/{id}/subtasks", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoRead}, todoController.GetSubtasks))).Methods("GET")
+	r.HandleFunc("/todos/{id}/subtasks", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, todoController.CreateSubtask))).Methods("POST")
+	r.HandleFunc("/todos/{id}/parent", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, todoController.SetTodoParent))).Methods("PUT")
+	r.HandleFunc("/todos/{id}/recurrence", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, todoController.SetTodoRecurrence))).Methods("PUT")
+	r.HandleFunc("/todos/{id}/series", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoRead}, todoController.GetTodoSeries))).Methods("GET")
+	r.HandleFunc("/todos/{id}/dependencies", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, todoController.AddDependency))).Methods("POST")
+	r.HandleFunc("/todos/{id}/dependencies/{dependsOnID}", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, todoController.RemoveDependency))).Methods("DELETE")
+	r.HandleFunc("/todos/{id}/move", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, todoController.MoveTodo))).Methods("POST")
+	r.HandleFunc("/todos/{id}/comments", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoRead}, commentController.GetComments))).Methods("GET")
+	r.HandleFunc("/todos/{id}/comments", authenticated(permissionMiddleware.AuthorizeTodoReader([]string{models.PermTodoRead}, commentController.CreateComment))).Methods("POST")
+	r.HandleFunc("/todos/{id}/comments/{commentID}", authenticated(permissionMiddleware.AuthorizeTodoReader([]string{models.PermTodoRead}, commentController.UpdateComment))).Methods("PUT")
+	r.HandleFunc("/todos/{id}/comments/{commentID}", authenticated(permissionMiddleware.AuthorizeTodoReader([]string{models.PermTodoRead}, commentController.DeleteComment))).Methods("DELETE")
+	r.HandleFunc("/todos/{id}/attachments", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoRead}, attachmentController.GetAttachments))).Methods("GET")
+	r.HandleFunc("/todos/{id}/attachments", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, attachmentController.UploadAttachment))).Methods("POST")
+	r.HandleFunc("/todos/{id}/attachments/{attachmentID}", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoRead}, attachmentController.DownloadAttachment))).Methods("GET")
+	r.HandleFunc("/todos/{id}/attachments/{attachmentID}", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, attachmentController.DeleteAttachment))).Methods("DELETE")
+	r.HandleFunc("/todos/{id}/restore", authenticated(permissionMiddleware.AuthorizeTrashedTodo([]string{models.PermTodoDelete}, models.TodoAccessEdit, todoController.RestoreTodo))).Methods("POST")
+	r.HandleFunc("/todos/{id}/history", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoRead}, todoController.GetTodoHistory))).Methods("GET")
+	r.HandleFunc("/todos/{id}/history/{revision}", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoRead}, todoController.GetTodoRevision))).Methods("GET")
+	r.HandleFunc("/todos/{id}/history/{revision}/revert", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, todoController.RevertTodo))).Methods("POST")
+	r.HandleFunc("/todos/{id}/list", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, todoController.SetTodoList))).Methods("PUT")
+	r.HandleFunc("/todos/{id}/tags", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, tagController.AttachTag))).Methods("POST")
+	r.HandleFunc("/todos/{id}/tags/{tagID}", authenticated(permissionMiddleware.AuthorizeTodo([]string{models.PermTodoWrite}, tagController.DetachTag))).Methods("DELETE")
+	r.HandleFunc("/trash", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoRead}, todoController.GetTrash))).Methods("GET")
+	r.HandleFunc("/trash/{id}", authenticated(permissionMiddleware.AuthorizeTrashedTodo([]string{models.PermTodoDelete}, models.TodoAccessOwner, todoController.PurgeTodo))).Methods("DELETE")
+	r.HandleFunc("/tags", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoRead}, tagController.GetTagsByUser))).Methods("GET")
+	r.HandleFunc("/tags", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoWrite}, tagController.CreateTag))).Methods("POST")
+	r.HandleFunc("/tags/{id}", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoDelete}, tagController.DeleteTag))).Methods("DELETE")
+	r.HandleFunc("/lists", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoRead}, listController.GetListsByUser))).Methods("GET")
+	r.HandleFunc("/lists", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoWrite}, listController.CreateList))).Methods("POST")
+	r.HandleFunc("/lists/{id}", authenticated(permissionMiddleware.AuthorizeList([]string{models.PermTodoRead}, listController.GetSingleList))).Methods("GET")
+	r.HandleFunc("/lists/{id}", authenticated(permissionMiddleware.AuthorizeList([]string{models.PermTodoWrite}, listController.UpdateList))).Methods("PUT")
+	r.HandleFunc("/lists/{id}", authenticated(permissionMiddleware.AuthorizeList([]string{models.PermTodoDelete}, listController.DeleteList))).Methods("DELETE")
+	r.HandleFunc("/lists/{id}/todos", authenticated(permissionMiddleware.AuthorizeList([]string{models.PermTodoRead}, todoController.GetListTodos))).Methods("GET")
+	r.HandleFunc("/boards", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoRead}, boardController.GetBoardsByUser))).Methods("GET")
+	r.HandleFunc("/boards", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoWrite}, boardController.CreateBoard))).Methods("POST")
+	r.HandleFunc("/boards/{id}", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoRead}, boardController.GetSingleBoard))).Methods("GET")
+	r.HandleFunc("/boards/{id}", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoDelete}, boardController.DeleteBoard))).Methods("DELETE")
+	r.HandleFunc("/boards/{id}/columns", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoWrite}, boardController.SetBoardColumns))).Methods("PUT")
+	r.HandleFunc("/boards/{id}/move", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoWrite}, boardController.MoveTodo))).Methods("POST")
+	r.HandleFunc("/workspaces", authenticated(workspaceController.GetWorkspacesByUser)).Methods("GET")
+	r.HandleFunc("/workspaces", authenticated(permissionMiddleware.Authorize([]string{models.PermTodoWrite}, workspaceController.CreateWorkspace))).Methods("POST")
+	r.HandleFunc("/workspaces/{id}/members", authenticated(permissionMiddleware.AuthorizeWorkspace(nil, models.WorkspaceRoleViewer, workspaceController.GetMembers))).Methods("GET")
+	r.HandleFunc("/workspaces/{id}/members", authenticated(permissionMiddleware.AuthorizeWorkspace(nil, models.WorkspaceRoleOwner, workspaceController.SetMember))).Methods("POST")
+	r.HandleFunc("/workspaces/{id}/members/{userID}", authenticated(permissionMiddleware.AuthorizeWorkspace(nil, models.WorkspaceRoleOwner, workspaceController.RemoveMember))).Methods("DELETE")
+	r.HandleFunc("/workspaces/{id}/todos", authenticated(permissionMiddleware.AuthorizeWorkspace([]string{models.PermTodoRead}, models.WorkspaceRoleViewer, todoController.GetWorkspaceTodos))).Methods("GET")
+	r.HandleFunc("/workspaces/{id}/todos", authenticated(permissionMiddleware.AuthorizeWorkspace([]string{models.PermTodoWrite}, models.WorkspaceRoleEditor, todoController.CreateWorkspaceTodo))).Methods("POST")
+	r.HandleFunc("/admin/permissions", authenticated(permissionMiddleware.Authorize([]string{models.PermUserAdmin}, permissionController.GetRolePermissions))).Methods("GET")
+	r.HandleFunc("/admin/roles/{role}/permissions", authenticated(permissionMiddleware.Authorize([]string{models.PermUserAdmin}, permissionController.SetRolePermissions))).Methods("PUT")
+	r.HandleFunc("/admin/users/{id}/role", authenticated(permissionMiddleware.Authorize([]string{models.PermUserAdmin}, permissionController.SetUserRole))).Methods("PUT")
+	r.HandleFunc("/admin/audit", authenticated(permissionMiddleware.Authorize([]string{models.PermUserAdmin}, auditController.GetAuditEvents))).Methods("GET")
+	r.HandleFunc("/admin/audit/export", authenticated(permissionMiddleware.Authorize([]string{models.PermUserAdmin}, auditController.ExportAuditEvents))).Methods("GET")
+	r.HandleFunc("/openapi.json", docsController.GetSpec).Methods("GET")
+	r.PathPrefix("/docs/").HandlerFunc(docsController.GetUI).Methods("GET")
+
+	// Every route must be described by the OpenAPI document
+	apiDocument, err := openapi.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := apiDocument.CheckRoutes(r); err != nil {
+		return nil, err
+	}
+	if os.Getenv("OPENAPI_VALIDATE_RESPONSES") == "true" {
+		r.Use(middlewares.NewOpenAPIMiddleware(apiDocument).ValidateResponses)
+	}
+
+	// Cross-origin access for browser applications, configured by comma separated lists
+	corsMiddleware := middlewares.NewCORSMiddleware(envList("CORS_ALLOWED_ORIGINS"))
+	if methods := envList("CORS_ALLOWED_METHODS"); methods != nil {
+		corsMiddleware.AllowedMethods = methods
+	}
+	if headers := envList("CORS_ALLOWED_HEADERS"); headers != nil {
+		corsMiddleware.AllowedHeaders = headers
+	}
+	if headers := envList("CORS_EXPOSED_HEADERS"); headers != nil {
+		corsMiddleware.ExposedHeaders = headers
+	}
+	corsMiddleware.AllowCredentials = os.Getenv("CORS_ALLOW_CREDENTIALS") == "true"
+	if maxAge, err := time.ParseDuration(os.Getenv("CORS_MAX_AGE")); err == nil {
+		corsMiddleware.MaxAge = maxAge
+	}
+
+	securityHeadersMiddleware := middlewares.NewSecurityHeadersMiddleware()
+	if maxAge, err := time.ParseDuration(os.Getenv("HSTS_MAX_AGE")); err == nil {
+		securityHeadersMiddleware.HSTSMaxAge = maxAge
+	}
+
+	return &Server{
+		Router:           r,
+		Handler:          securityHeadersMiddleware.Handler(corsMiddleware.Handler(r)),
+		todoStore:        todoStore,
+		attachmentStore:  attachmentStore,
+		idempotencyStore: idempotencyStore,
+		blobs:            blobs,
+		limits:           limits,
+		trashRetention:   trashRetention,
+	}, nil
+}
+
+// StartMaintenance starts the background jobs that purge deleted attachment
+// contents, old trash, expired idempotency keys and full rate limit buckets.
+func (s *Server) StartMaintenance() {
+	go purgeDeletedBlobs(s.attachmentStore, s.blobs)
+	go purgeTrash(s.todoStore, s.trashRetention)
+	go purgeIdempotencyKeys(s.idempotencyStore)
+	if store, ok := s.limits.(*ratelimit.PostgresStore); ok {
+		go purgeRateLimitBuckets(store)
+	}
+}
+
+// purgeDeletedBlobs periodically removes the stored contents of deleted attachments.
+func purgeDeletedBlobs(attachmentStore *models.AttachmentStore, blobs blobstore.BlobStore) {
+	for range time.Tick(time.Minute) {
+		if _, err := attachmentStore.PurgeDeletedBlobs(context.Background(), blobs, 100); err != nil {
+			log.Printf("Error purging attachment contents: %v", err)
+		}
+	}
+}
+
+// purgeTrash periodically deletes the todos that have been in the trash for longer than retention.
+func purgeTrash(todoStore *models.TodoStore, retention time.Duration) {
+	for range time.Tick(time.Hour) {
+		purged, err := todoStore.PurgeTrash(retention)
+		if err != nil {
+			log.Printf("Error purging trash: %v", err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("Purged %d todos from the trash", purged)
+		}
+	}
+}
+
+// purgeIdempotencyKeys periodically deletes expired idempotency keys and their stored responses.
+func purgeIdempotencyKeys(idempotencyStore *models.IdempotencyStore) {
+	for range time.Tick(time.Hour) {
+		if _, err := idempotencyStore.PurgeExpired(); err != nil {
+			log.Printf("Error purging idempotency keys: %v", err)
+		}
+	}
+}
+
+// purgeRateLimitBuckets periodically deletes the rate limit buckets that are full again.
+func purgeRateLimitBuckets(store *ratelimit.PostgresStore) {
+	for range time.Tick(time.Hour) {
+		if _, err := store.Purge(context.Background()); err != nil {
+			log.Printf("Error purging rate limit buckets: %v", err)
+		}
+	}
+}
+
+// envList splits the comma separated list in the environment variable name,
+// returning nil if it is not set.
+func envList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"bytes"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+	// htmlPolicy allows the formatting produced by markdown and drops scripts,
+	// styles, event handlers and unsafe links.
+	htmlPolicy = bluemonday.UGCPolicy()
+)
+
+// RenderMarkdown converts GitHub flavored markdown to HTML that is safe to
+// embed in a page. Raw HTML in the source is sanitized, not trusted.
+func RenderMarkdown(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+
+	return htmlPolicy.Sanitize(buf.String()), nil
+}